@@ -4,5 +4,7 @@ go 1.12
 
 require (
 	github.com/spf13/cobra v0.0.6
+	go.etcd.io/bbolt v1.3.5
+	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/klog v1.0.0
 )
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -11,31 +11,44 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
+	"github.com/bparees/sippy/pkg/api"
+	"github.com/bparees/sippy/pkg/cache"
+	"github.com/bparees/sippy/pkg/config"
+	"github.com/bparees/sippy/pkg/history"
 	"github.com/bparees/sippy/pkg/html"
+	"github.com/bparees/sippy/pkg/knownissues"
+	"github.com/bparees/sippy/pkg/snapshot"
 	"github.com/bparees/sippy/pkg/testgrid"
 	"github.com/bparees/sippy/pkg/util"
 )
 
 var (
-	dashboardTemplate = "redhat-openshift-ocp-release-%s-%s"
-	TagStripRegex     = regexp.MustCompile(`\[Skipped:.*?\]|\[Suite:.*\]`)
+	TagStripRegex = regexp.MustCompile(`\[Skipped:.*?\]|\[Suite:.*\]`)
 )
 
 type RawData struct {
 	ByAll         map[string]util.AggregateTestResult
 	ByJob         map[string]util.AggregateTestResult
-	ByPlatform    map[string]util.AggregateTestResult
+	ByVariant     map[string]map[string]util.AggregateTestResult
 	BySig         map[string]util.AggregateTestResult
+	ByComponent   map[string]util.AggregateTestResult
+	ByTeam        map[string]util.AggregateTestResult
 	FailureGroups map[string]util.JobRunResult
 	JobDetails    []testgrid.JobDetails
+	Trends        util.Trends
 }
 
 type Analyzer struct {
@@ -44,109 +57,97 @@ type Analyzer struct {
 	Report         util.TestReport
 	LastUpdateTime time.Time
 	Release        string
+	// EndTime is the time the analysis window is counted back from, now if it is not set.
+	EndTime time.Time
+	// PrevReport is the report for the comparison period, if one was requested.
+	PrevReport *util.TestReport
 }
 
-func loadJobSummaries(dashboard string, storagePath string) (map[string]testgrid.JobSummary, time.Time, error) {
-	jobs := make(map[string]testgrid.JobSummary)
-	url := fmt.Sprintf("https://testgrid.k8s.io/%s/summary", dashboard)
-
-	var buf *bytes.Buffer
-	filename := storagePath + "/" + "\"" + strings.ReplaceAll(url, "/", "-") + "\""
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return jobs, time.Time{}, fmt.Errorf("Could not read local data file %s: %v", filename, err)
-	}
-	buf = bytes.NewBuffer(b)
-	f, _ := os.Stat(filename)
-	f.ModTime()
-
-	err = json.NewDecoder(buf).Decode(&jobs)
-	if err != nil {
-		return nil, time.Time{}, err
+func newAnalyzer(release string, options *Options) Analyzer {
+	return Analyzer{
+		Release: release,
+		Options: options,
+		RawData: RawData{
+			ByAll:         make(map[string]util.AggregateTestResult),
+			ByJob:         make(map[string]util.AggregateTestResult),
+			ByVariant:     make(map[string]map[string]util.AggregateTestResult),
+			BySig:         make(map[string]util.AggregateTestResult),
+			ByComponent:   make(map[string]util.AggregateTestResult),
+			ByTeam:        make(map[string]util.AggregateTestResult),
+			FailureGroups: make(map[string]util.JobRunResult),
+			Trends:        util.NewTrends(options.StartDay, options.EndDay, options.TrendBucketDays),
+		},
 	}
-
-	return jobs, f.ModTime(), nil
-
 }
 
-func downloadJobSummaries(dashboard string, storagePath string) error {
-	url := fmt.Sprintf("https://testgrid.k8s.io/%s/summary", dashboard)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("Non-200 response code fetching job summary: %v", resp)
-	}
-	filename := storagePath + "/" + "\"" + strings.ReplaceAll(url, "/", "-") + "\""
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
+// rules are the rules of the analysis, the current rules if the options have none.
+func (a *Analyzer) rules() *util.Rules {
+	if a.Options.Rules == nil {
+		return util.CurrentRules()
 	}
-
-	buf := bytes.NewBuffer([]byte{})
-	io.Copy(buf, resp.Body)
-
-	_, err = f.Write(buf.Bytes())
-	return err
+	return a.Options.Rules
 }
 
-func loadJobDetails(dashboard, jobName, storagePath string) (testgrid.JobDetails, error) {
-	details := testgrid.JobDetails{
-		Name: jobName,
+// endTime is the time the analysis window is counted back from.
+func (a *Analyzer) endTime() time.Time {
+	if a.EndTime.IsZero() {
+		return time.Now()
 	}
-
-	url := fmt.Sprintf("https://testgrid.k8s.io/%s/table?&show-stale-tests=&tab=%s", dashboard, jobName)
-
-	var buf *bytes.Buffer
-	filename := storagePath + "/" + "\"" + strings.ReplaceAll(url, "/", "-") + "\""
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return details, fmt.Errorf("Could not read local data file %s: %v", filename, err)
-	}
-	buf = bytes.NewBuffer(b)
-
-	err = json.NewDecoder(buf).Decode(&details)
-	if err != nil {
-		return details, err
-	}
-	details.TestGridUrl = fmt.Sprintf("https://testgrid.k8s.io/%s#%s", dashboard, jobName)
-	return details, nil
+	return a.EndTime
 }
 
 // https://testgrid.k8s.io/redhat-openshift-ocp-release-4.4-informing/table?&show-stale-tests=&tab=release-openshift-origin-installer-e2e-azure-compact-4.4
 
 // https://testgrid.k8s.io/redhat-openshift-ocp-release-4.4-informing#release-openshift-origin-installer-e2e-azure-compact-4.4&show-stale-tests=&sort-by-failures=
 
-func downloadJobDetails(dashboard, jobName, storagePath string) error {
-	url := fmt.Sprintf("https://testgrid.k8s.io/%s/table?&show-stale-tests=&tab=%s", dashboard, jobName)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+// recordRun adds the result of the test in column i of the job to the job run it belongs to.
+func (a *Analyzer) recordRun(job testgrid.JobDetails, variant util.Variant, test testgrid.Test, i int, failed bool) {
+	joburl := fmt.Sprintf("%s/%s/%s", a.Options.ProwURL, job.Query, job.ChangeLists[i])
+	jrr, ok := a.RawData.FailureGroups[joburl]
+	if !ok {
+		jrr = util.JobRunResult{
+			Job:            job.Name,
+			Url:            joburl,
+			TestGridJobUrl: job.TestGridUrl,
+			DashboardType:  job.DashboardType,
+			Variant:        variant,
+			Timestamp:      time.Unix(0, int64(job.Timestamps[i])*int64(time.Millisecond)),
+		}
 	}
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("Non-200 response code fetching job details: %v", resp)
+	jrr.TestNames = append(jrr.TestNames, test.Name)
+	if failed {
+		jrr.TestFailures++
+		jrr.FailedTestNames = append(jrr.FailedTestNames, test.Name)
 	}
-
-	filename := storagePath + "/" + "\"" + strings.ReplaceAll(url, "/", "-") + "\""
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
+	if test.Name == "Overall" {
+		if failed {
+			jrr.Failed = true
+		} else {
+			jrr.Succeeded = true
+		}
 	}
+	a.RawData.FailureGroups[joburl] = jrr
+}
 
-	buf := bytes.NewBuffer([]byte{})
-	io.Copy(buf, resp.Body)
-
-	_, err = f.Write(buf.Bytes())
-	return err
-
+// recordTrend adds the result of the test in column i of the job to the trends.  The result of the Overall test
+// is the result of the job run.
+func (a *Analyzer) recordTrend(job testgrid.JobDetails, variant util.Variant, test testgrid.Test, meta util.TestMeta, i int, endTime time.Time, passed bool) {
+	trends := a.RawData.Trends
+	age := endTime.Sub(time.Unix(0, int64(job.Timestamps[i])*int64(time.Millisecond)))
+	trends.Add(trends.Tests, test.Name, age, passed)
+	trends.Add(trends.Sigs, meta.Owner.Sig, age, passed)
+	if test.Name == "Overall" {
+		trends.Add(trends.Jobs, job.Name, age, passed)
+		trends.AddVariant(variant, age, passed)
+	}
 }
-func (a *Analyzer) processTest(job testgrid.JobDetails, platforms []string, test testgrid.Test, meta util.TestMeta, startCol, endCol int) {
+
+func (a *Analyzer) processTest(job testgrid.JobDetails, variant util.Variant, test testgrid.Test, meta util.TestMeta, startCol, endCol int, endTime time.Time) {
 	col := 0
-	passed := 0
-	failed := 0
+	counts := util.TestCounts{}
+	// columns are ordered newest first, so a failure following a pass in this walk is a failed run that
+	// passed on the next run of the job.
+	lastCountedPassed := false
 	for _, result := range test.Statuses {
 		if col > endCol {
 			break
@@ -169,59 +170,54 @@ func (a *Analyzer) processTest(job testgrid.JobDetails, platforms []string, test
 		if col < startCol {
 			continue
 		}
-		switch result.Value {
-		case 1:
-			for i := col; i < col+remaining && i < endCol; i++ {
-				passed++
-				joburl := fmt.Sprintf("https://prow.svc.ci.openshift.org/view/gcs/%s/%s", job.Query, job.ChangeLists[i])
-				jrr, ok := a.RawData.FailureGroups[joburl]
-				if !ok {
-					jrr = util.JobRunResult{
-						Job:            job.Name,
-						Url:            joburl,
-						TestGridJobUrl: job.TestGridUrl,
-					}
-				}
-				jrr.TestNames = append(jrr.TestNames, test.Name)
-				if test.Name == "Overall" {
-					jrr.Succeeded = true
-				}
-				a.RawData.FailureGroups[joburl] = jrr
-			}
-		case 12:
-			for i := col; i < col+remaining && i < endCol; i++ {
-				failed++
-				joburl := fmt.Sprintf("https://prow.svc.ci.openshift.org/view/gcs/%s/%s", job.Query, job.ChangeLists[i])
-				jrr, ok := a.RawData.FailureGroups[joburl]
-				if !ok {
-					jrr = util.JobRunResult{
-						Job:            job.Name,
-						Url:            joburl,
-						TestGridJobUrl: job.TestGridUrl,
-					}
-				}
-				jrr.TestNames = append(jrr.TestNames, test.Name)
-				jrr.TestFailures++
-				if test.Name == "Overall" {
-					jrr.Failed = true
-				}
-				a.RawData.FailureGroups[joburl] = jrr
+		// runs with no result, or a result that says nothing about the test (e.g. still running), are not counted.
+		if !result.Value.Counted() {
+			klog.V(4).Infof("Ignoring %d results with status %s for test %s in job %s\n", remaining, result.Value, test.Name, job.Name)
+			col += remaining
+			continue
+		}
+		for i := col; i < col+remaining && i < endCol; i++ {
+			switch {
+			case result.Value.IsPass():
+				counts.Successes++
+			case result.Value.IsFailure():
+				counts.Failures++
+			}
+			if result.Value.IsFlake() {
+				counts.Flakes++
+			}
+			if result.Value.IsTimeout() {
+				counts.Timeouts++
+			}
+			if result.Value.IsFailure() && lastCountedPassed {
+				counts.Flips++
 			}
+			lastCountedPassed = result.Value.IsPass()
+			a.recordRun(job, variant, test, i, result.Value.IsFailure())
+			a.recordTrend(job, variant, test, meta, i, endTime, result.Value.IsPass())
 		}
 		col += remaining
 	}
 
-	util.AddTestResult("all", a.RawData.ByAll, test.Name, meta, passed, failed)
-	util.AddTestResult(job.Name, a.RawData.ByJob, test.Name, meta, passed, failed)
-	for _, platform := range platforms {
-		util.AddTestResult(platform, a.RawData.ByPlatform, test.Name, meta, passed, failed)
+	util.AddTestResult("all", a.RawData.ByAll, test.Name, meta, counts)
+	util.AddTestResult(job.Name, a.RawData.ByJob, test.Name, meta, counts)
+	for dimension, value := range variant {
+		byValue, ok := a.RawData.ByVariant[dimension]
+		if !ok {
+			byValue = make(map[string]util.AggregateTestResult)
+			a.RawData.ByVariant[dimension] = byValue
+		}
+		util.AddTestResult(value, byValue, test.Name, meta, counts)
 	}
-	util.AddTestResult(meta.Sig, a.RawData.BySig, test.Name, meta, passed, failed)
+	util.AddTestResult(meta.Owner.Sig, a.RawData.BySig, test.Name, meta, counts)
+	util.AddTestResult(meta.Owner.Component, a.RawData.ByComponent, test.Name, meta, counts)
+	util.AddTestResult(meta.Owner.Team, a.RawData.ByTeam, test.Name, meta, counts)
 }
 
 func (a *Analyzer) processJobDetails(job testgrid.JobDetails, testMeta map[string]util.TestMeta) {
 
-	startCol, endCol := util.ComputeLookback(a.Options.StartDay, a.Options.EndDay, job.Timestamps)
+	endTime := a.endTime()
+	startCol, endCol := util.ComputeLookbackFrom(endTime, a.Options.StartDay, a.Options.EndDay, job.Timestamps)
 	for i, test := range job.Tests {
 		klog.V(2).Infof("Analyzing results from %d to %d from job %s for test %s\n", startCol, endCol, job.Name, test.Name)
 
@@ -231,9 +227,9 @@ func (a *Analyzer) processJobDetails(job testgrid.JobDetails, testMeta map[strin
 		meta, ok := testMeta[test.Name]
 		if !ok {
 			meta = util.TestMeta{
-				Name: test.Name,
-				Jobs: make(map[string]interface{}),
-				Sig:  util.FindSig(test.Name),
+				Name:  test.Name,
+				Jobs:  make(map[string]interface{}),
+				Owner: a.rules().FindOwner(test.Name),
 			}
 			if a.Options.FindBugs {
 				meta.BugList, meta.BugErr = util.FindBug(test.Name)
@@ -247,7 +243,7 @@ func (a *Analyzer) processJobDetails(job testgrid.JobDetails, testMeta map[strin
 		// update test metadata
 		testMeta[test.Name] = meta
 
-		a.processTest(job, util.FindPlatform(job.Name), test, meta, startCol, endCol)
+		a.processTest(job, a.rules().FindVariant(job.Name), test, meta, startCol, endCol, endTime)
 	}
 }
 
@@ -258,123 +254,162 @@ func (a *Analyzer) analyze() {
 		klog.V(2).Infof("processing test details for job %s\n", details.Name)
 		a.processJobDetails(details, testMeta)
 	}
+	for _, jrr := range a.RawData.FailureGroups {
+		sort.Strings(jrr.TestNames)
+		sort.Strings(jrr.FailedTestNames)
+	}
 }
 
-func (a *Analyzer) loadData(releases []string, storagePath string) {
+func (a *Analyzer) loadData(releases []string, source testgrid.DataSource) {
 	var jobFilter *regexp.Regexp
 	if len(a.Options.JobFilter) > 0 {
 		jobFilter = regexp.MustCompile(a.Options.JobFilter)
 	}
 
 	for _, release := range releases {
-
-		dashboard := fmt.Sprintf(dashboardTemplate, release, "blocking")
-		blockingJobs, ts, err := loadJobSummaries(dashboard, storagePath)
-		if err != nil {
-			klog.Errorf("Error loading dashboard page %s: %v\n", dashboard, err)
-			continue
-		}
-		a.LastUpdateTime = ts
-		for jobName, job := range blockingJobs {
-			if util.RelevantJob(jobName, job.OverallStatus, jobFilter) {
-				klog.V(4).Infof("Job %s has bad status %s\n", jobName, job.OverallStatus)
-				details, err := loadJobDetails(dashboard, jobName, storagePath)
-				if err != nil {
-					klog.Errorf("Error loading job details for %s: %v\n", jobName, err)
-				} else {
-					a.RawData.JobDetails = append(a.RawData.JobDetails, details)
+		for _, dashboard := range testgrid.ReleaseDashboards(a.Options.Dashboards, release) {
+			jobs, ts, err := source.JobSummaries(dashboard.Name)
+			if err != nil {
+				klog.Errorf("Error loading dashboard page %s: %v\n", dashboard.Name, err)
+				continue
+			}
+			if ts.After(a.LastUpdateTime) {
+				a.LastUpdateTime = ts
+			}
+			for jobName, job := range jobs {
+				if util.RelevantJob(jobName, job.OverallStatus, jobFilter) {
+					klog.V(4).Infof("Job %s has bad status %s\n", jobName, job.OverallStatus)
+					details, err := source.JobDetails(dashboard.Name, jobName)
+					if err != nil {
+						klog.Errorf("Error loading job details for %s: %v\n", jobName, err)
+					} else {
+						details.DashboardType = dashboard.Type
+						a.RawData.JobDetails = append(a.RawData.JobDetails, details)
+					}
 				}
 			}
 		}
+	}
+}
 
-		dashboard = fmt.Sprintf(dashboardTemplate, release, "informing")
-		informingJobs, _, err := loadJobSummaries(dashboard, storagePath)
-		if err != nil {
-			klog.Errorf("Error load dashboard page %s: %v\n", dashboard, err)
-			continue
-		}
-
-		for jobName, job := range informingJobs {
-			if util.RelevantJob(jobName, job.OverallStatus, jobFilter) {
-				klog.V(4).Infof("Job %s has bad status %s\n", jobName, job.OverallStatus)
-				details, err := loadJobDetails(dashboard, jobName, storagePath)
-				if err != nil {
-					klog.Errorf("Error loading job details for %s: %v\n", jobName, err)
-				} else {
-					a.RawData.JobDetails = append(a.RawData.JobDetails, details)
-				}
-			}
+// releaseDashboards are the names of the testgrid dashboards of the releases.
+func releaseDashboards(dashboards []testgrid.Dashboard, releases []string) []string {
+	names := []string{}
+	for _, release := range releases {
+		for _, dashboard := range testgrid.ReleaseDashboards(dashboards, release) {
+			names = append(names, dashboard.Name)
 		}
 	}
+	return names
 }
 
-func downloadData(releases []string, filter string, storagePath string) {
+// ingestData records the runs of the dashboards' jobs from the data source into the history database.
+func ingestData(source testgrid.DataSource, dashboards []string, filter string, database string) error {
 	var jobFilter *regexp.Regexp
 	if len(filter) > 0 {
 		jobFilter = regexp.MustCompile(filter)
 	}
 
-	for _, release := range releases {
+	db, err := history.Open(database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-		dashboard := fmt.Sprintf(dashboardTemplate, release, "blocking")
-		err := downloadJobSummaries(dashboard, storagePath)
-		if err != nil {
-			klog.Errorf("Error fetching dashboard page %s: %v\n", dashboard, err)
-			continue
-		}
-		blockingJobs, _, err := loadJobSummaries(dashboard, storagePath)
-		if err != nil {
-			klog.Errorf("Error loading dashboard page %s: %v\n", dashboard, err)
-			continue
-		}
+	added, err := db.IngestFrom(source, dashboards, func(jobName string, job testgrid.JobSummary) bool {
+		return util.RelevantJob(jobName, job.OverallStatus, jobFilter)
+	})
+	klog.Infof("Recorded %d new job runs in %s\n", added, database)
+	return err
+}
 
-		for jobName, job := range blockingJobs {
-			if util.RelevantJob(jobName, job.OverallStatus, jobFilter) {
-				klog.V(4).Infof("Job %s has bad status %s\n", jobName, job.OverallStatus)
-				err := downloadJobDetails(dashboard, jobName, storagePath)
-				if err != nil {
-					klog.Errorf("Error fetching job details for %s: %v\n", jobName, err)
-				}
-			}
-		}
+// downloadData fetches the dashboards of the releases into a new snapshot under storagePath and makes it the current
+// snapshot once the fetch completes.  An error is returned if the snapshot could not be completed, the downloads
+// that failed without preventing that are listed in the summary.
+func downloadData(ctx context.Context, releases []string, dashboards []string, filter string, testGridURL string, fetchOptions testgrid.FetchOptions, storagePath string, keepSnapshots int, database string) (testgrid.FetchSummary, error) {
+	var jobFilter *regexp.Regexp
+	if len(filter) > 0 {
+		jobFilter = regexp.MustCompile(filter)
+	}
 
-		dashboard = fmt.Sprintf(dashboardTemplate, release, "informing")
-		err = downloadJobSummaries(dashboard, storagePath)
-		if err != nil {
-			klog.Errorf("Error fetching dashboard page %s: %v\n", dashboard, err)
-			continue
-		}
-		informingJobs, _, err := loadJobSummaries(dashboard, storagePath)
-		if err != nil {
-			klog.Errorf("Error fetching dashboard page %s: %v\n", dashboard, err)
-			continue
+	snapshotPath, err := snapshot.Create(storagePath, time.Now())
+	if err != nil {
+		return testgrid.FetchSummary{}, fmt.Errorf("unable to create snapshot in %s: %v", storagePath, err)
+	}
+	klog.Infof("Fetching data into %s\n", snapshotPath)
+
+	fetcher := testgrid.NewFetcher(testGridURL, fetchOptions)
+	summary, err := fetcher.Fetch(ctx, dashboards, func(jobName string, job testgrid.JobSummary) bool {
+		return util.RelevantJob(jobName, job.OverallStatus, jobFilter)
+	}, snapshotPath)
+
+	klog.Infof("Fetched %d dashboards and %d jobs, %d downloads failed\n", summary.Dashboards, summary.Jobs, len(summary.Failures))
+	failures := []string{}
+	failedDashboards := 0
+	for _, failure := range summary.Failures {
+		if len(failure.Job) > 0 {
+			klog.Infof("Failed to fetch job %s from dashboard %s: %v\n", failure.Job, failure.Dashboard, failure.Err)
+		} else {
+			klog.Infof("Failed to fetch dashboard %s: %v\n", failure.Dashboard, failure.Err)
+			failedDashboards++
 		}
+		failures = append(failures, failure.URL)
+	}
+	if err != nil {
+		return summary, fmt.Errorf("fetch interrupted, %s was left incomplete: %v", snapshotPath, err)
+	}
+	if failedDashboards == summary.Dashboards {
+		return summary, fmt.Errorf("no dashboards could be fetched, %s was left incomplete", snapshotPath)
+	}
 
-		for jobName, job := range informingJobs {
-			if util.RelevantJob(jobName, job.OverallStatus, jobFilter) {
-				klog.V(4).Infof("Job %s has bad status %s\n", jobName, job.OverallStatus)
-				err := downloadJobDetails(dashboard, jobName, storagePath)
-				if err != nil {
-					klog.Errorf("Error fetching job details for %s: %v\n", jobName, err)
-				}
-			}
+	err = snapshot.Commit(snapshotPath, snapshot.Manifest{
+		Created:    summary.Started,
+		Releases:   releases,
+		Dashboards: summary.Dashboards,
+		Jobs:       summary.Jobs,
+		Failures:   failures,
+	})
+	if err != nil {
+		return summary, fmt.Errorf("unable to complete snapshot %s: %v", snapshotPath, err)
+	}
+	klog.Infof("Snapshot %s is now current\n", snapshotPath)
+
+	if len(database) > 0 {
+		if err := ingestData(testgrid.NewLocalDataSource(snapshotPath, testGridURL), dashboards, filter, database); err != nil {
+			klog.Errorf("Error recording snapshot %s in %s: %v\n", snapshotPath, database, err)
 		}
 	}
+
+	if err := snapshot.Prune(storagePath, keepSnapshots); err != nil {
+		klog.Errorf("Error pruning old snapshots from %s: %v\n", storagePath, err)
+	}
+
+	return summary, nil
 }
 
-// returns top ten failing tests w/o a bug and top ten with a bug(in that order)
-func getTopFailingTests(result map[string]util.SortedAggregateTestResult) ([]*util.TestResult, []*util.TestResult) {
+// returns top ten failing tests w/o a bug, top twenty with a bug and top twenty with a known issue (in that order).
+// Tests with a known issue are not searched for bugs and are left out of the other lists.
+func getTopFailingTests(result map[string]util.SortedAggregateTestResult, rules *util.Rules, knownIssues *knownissues.Registry) ([]*util.TestResult, []*util.TestResult, []*util.TestResult) {
 	topTestsWithoutBug := []*util.TestResult{}
 	topTestsWithBug := []*util.TestResult{}
+	topTestsWithKnownIssue := []*util.TestResult{}
 	all := result["all"]
 	withoutbugcount := 0
 	withbugcount := 0
+	now := time.Now()
 	// look at the top 100 failing tests, try to create a list of the top 20 failures with bugs and without bugs.
 	// limit to 100 so we don't hammer search.svc.ci too hard if we can't find 20 failures with bugs in the first 100.
 	for i := 0; (withbugcount < 20 || withoutbugcount < 10) && i < 100 && i < len(all.TestResults); i++ {
 
 		test := all.TestResults[i]
-		if util.IgnoreTestRegex.MatchString(test.Name) {
+		if rules.IgnoreTest(test.Name) {
+			continue
+		}
+		if issue, ok := knownIssues.Match(test.Name, now); ok {
+			if len(topTestsWithKnownIssue) < 20 {
+				test.KnownIssue = &issue
+				topTestsWithKnownIssue = append(topTestsWithKnownIssue, &test)
+			}
 			continue
 		}
 		test.BugList, test.BugErr = util.FindBug(test.Name)
@@ -391,19 +426,29 @@ func getTopFailingTests(result map[string]util.SortedAggregateTestResult) ([]*ut
 			withbugcount++
 		}
 	}
-	return topTestsWithoutBug, topTestsWithBug
+	return topTestsWithoutBug, topTestsWithBug, topTestsWithKnownIssue
 }
 
 func (a *Analyzer) prepareTestReport(prev bool) {
 	util.ComputePercentages(a.RawData.ByAll)
-	util.ComputePercentages(a.RawData.ByPlatform)
+	for _, byValue := range a.RawData.ByVariant {
+		util.ComputePercentages(byValue)
+	}
 	util.ComputePercentages(a.RawData.ByJob)
 	util.ComputePercentages(a.RawData.BySig)
+	util.ComputePercentages(a.RawData.ByComponent)
+	util.ComputePercentages(a.RawData.ByTeam)
+	a.RawData.Trends.ComputePercentages()
 
 	byAll := util.GenerateSortedResults(a.RawData.ByAll, a.Options.MinTestRuns, a.Options.TestSuccessThreshold)
-	byPlatform := util.GenerateSortedResults(a.RawData.ByPlatform, a.Options.MinTestRuns, a.Options.TestSuccessThreshold)
+	byVariant := make(map[string]map[string]util.SortedAggregateTestResult)
+	for dimension, byValue := range a.RawData.ByVariant {
+		byVariant[dimension] = util.GenerateSortedResults(byValue, a.Options.MinTestRuns, a.Options.TestSuccessThreshold)
+	}
 	byJob := util.GenerateSortedResults(a.RawData.ByJob, a.Options.MinTestRuns, a.Options.TestSuccessThreshold)
 	bySig := util.GenerateSortedResults(a.RawData.BySig, a.Options.MinTestRuns, a.Options.TestSuccessThreshold)
+	byComponent := util.GenerateSortedResults(a.RawData.ByComponent, a.Options.MinTestRuns, a.Options.TestSuccessThreshold)
+	byTeam := util.GenerateSortedResults(a.RawData.ByTeam, a.Options.MinTestRuns, a.Options.TestSuccessThreshold)
 
 	filteredFailureGroups := util.FilterFailureGroups(a.RawData.FailureGroups, a.Options.FailureClusterThreshold)
 	jobPassRate := util.ComputeJobPassRate(a.RawData.FailureGroups)
@@ -411,36 +456,131 @@ func (a *Analyzer) prepareTestReport(prev bool) {
 	a.Report = util.TestReport{
 		Release:       a.Release,
 		All:           byAll,
-		ByPlatform:    byPlatform,
+		ByVariant:     byVariant,
 		ByJob:         byJob,
 		BySig:         bySig,
+		ByComponent:   byComponent,
+		ByTeam:        byTeam,
 		FailureGroups: filteredFailureGroups,
 		JobPassRate:   jobPassRate,
 		Timestamp:     a.LastUpdateTime,
+		StartDay:      a.Options.StartDay,
+		EndDay:        a.Options.EndDay,
 	}
 
+	a.Report.Flakes = util.TopFlakyTests(a.RawData.ByAll["all"], a.rules(), a.Options.MinTestRuns, 20)
+
 	if !prev {
-		topFailingTestsWithoutBug, topFailingTestsWithBug := getTopFailingTests(byAll)
+		topFailingTestsWithoutBug, topFailingTestsWithBug, topFailingTestsWithKnownIssue := getTopFailingTests(byAll, a.rules(), a.Options.KnownIssues)
 		a.Report.TopFailingTestsWithBug = topFailingTestsWithBug
 		a.Report.TopFailingTestsWithoutBug = topFailingTestsWithoutBug
+		a.Report.TopFailingTestsWithKnownIssue = topFailingTestsWithKnownIssue
+		a.Report.KnownIssues = a.knownIssueStatuses(byAll["all"])
 	}
 
 }
 
+// knownIssueStatuses checks the known issues against the failing tests and the statuses of their bugs, which were
+// looked up before the analysis.
+func (a *Analyzer) knownIssueStatuses(all util.SortedAggregateTestResult) []knownissues.Status {
+	registry := a.Options.KnownIssues
+	if len(registry.Issues()) == 0 {
+		return []knownissues.Status{}
+	}
+	failing := []string{}
+	for _, test := range all.TestResults {
+		failing = append(failing, test.Name)
+	}
+	return registry.Statuses(failing, a.Options.BugStatuses, time.Now())
+}
+
 func (a *Analyzer) printReport() {
 	a.prepareTestReport(false)
 	switch a.Options.Output {
 	case "json":
-		a.printJsonReport()
+		json.NewEncoder(os.Stdout).Encode(a.jsonReport())
 	case "text":
 		a.printTextReport()
+		a.printComparisonSummary()
 	case "dashboard":
 		a.printDashboardReport()
+		a.printComparisonSummary()
+	}
+}
+
+// printReports prints the report of each release.  The json output is always keyed by release, so it has the same
+// shape however many releases there are.  The text outputs print a single release on its own, and several releases
+// one after another under a heading.
+func printReports(analyzers []Analyzer, output string) {
+	if output == "json" {
+		reports := make(map[string]interface{}, len(analyzers))
+		for i := range analyzers {
+			analyzers[i].prepareTestReport(false)
+			reports[analyzers[i].Release] = analyzers[i].jsonReport()
+		}
+		json.NewEncoder(os.Stdout).Encode(reports)
+		return
+	}
+	if len(analyzers) == 1 {
+		analyzers[0].printReport()
+		return
+	}
+	for i, analyzer := range analyzers {
+		if i > 0 {
+			fmt.Printf("\n\n\n")
+		}
+		fmt.Printf("################## Release %s (data as of %s) ##################\n\n", analyzer.Release, analyzer.LastUpdateTime.Format("Jan 2 15:04 2006 MST"))
+		analyzer.printReport()
+	}
+}
+
+// jsonReport is the report, along with the report of the comparison period when one was requested.
+func (a *Analyzer) jsonReport() interface{} {
+	if a.PrevReport != nil {
+		return struct {
+			Current  util.TestReport `json:"current"`
+			Previous util.TestReport `json:"previous"`
+		}{a.Report, *a.PrevReport}
 	}
+	return a.Report
 }
-func (a *Analyzer) printJsonReport() {
-	enc := json.NewEncoder(os.Stdout)
-	enc.Encode(a.Report)
+
+// printComparisonSummary prints the overall pass rates of the comparison period next to those of the analyzed period.
+func (a *Analyzer) printComparisonSummary() {
+	if a.PrevReport == nil {
+		return
+	}
+	jobPassRate := func(report util.TestReport) (int, float64) {
+		successes, failures := 0, 0
+		for _, job := range report.JobPassRate {
+			successes += job.Successes
+			failures += job.Failures
+		}
+		return successes + failures, util.Percent(successes, failures)
+	}
+
+	fmt.Printf("\n\n================== Comparison: Days %d-%d vs Days %d-%d ==================\n", a.Report.StartDay, a.Report.EndDay, a.PrevReport.StartDay, a.PrevReport.EndDay)
+	all, allPrev := a.Report.All["all"], a.PrevReport.All["all"]
+	fmt.Printf("Test Pass Percentage: %0.2f (%d runs) vs %0.2f (%d runs)\n", all.TestPassPercentage, all.Successes+all.Failures, allPrev.TestPassPercentage, allPrev.Successes+allPrev.Failures)
+	runs, p := jobPassRate(a.Report)
+	runsPrev, pPrev := jobPassRate(*a.PrevReport)
+	fmt.Printf("Job Pass Percentage: %0.2f (%d runs) vs %0.2f (%d runs)\n", p, runs, pPrev, runsPrev)
+
+	for _, dimension := range a.rules().VariantDimensions() {
+		jobsByVariantPrev := make(map[string]util.JobResult)
+		for _, v := range util.SummarizeJobsByVariant(*a.PrevReport, dimension) {
+			jobsByVariantPrev[v.Variant] = v
+		}
+		for _, v := range util.SummarizeJobsByVariant(a.Report, dimension) {
+			name := util.VariantName(dimension, v.Variant)
+			prev, ok := jobsByVariantPrev[v.Variant]
+			if !ok {
+				fmt.Printf("Variant %s Job Pass Percentage: %0.2f%% (%d runs) vs NA\n", name, util.Percent(v.Successes, v.Failures), v.Successes+v.Failures)
+				continue
+			}
+			fmt.Printf("Variant %s Job Pass Percentage: %0.2f%% (%d runs) vs %0.2f%% (%d runs)\n", name, util.Percent(v.Successes, v.Failures), v.Successes+v.Failures, util.Percent(prev.Successes, prev.Failures), prev.Successes+prev.Failures)
+		}
+	}
 }
 
 func (a *Analyzer) printDashboardReport() {
@@ -448,15 +588,17 @@ func (a *Analyzer) printDashboardReport() {
 	all := a.Report.All["all"]
 	fmt.Printf("Passing test runs: %d\n", all.Successes)
 	fmt.Printf("Failing test runs: %d\n", all.Failures)
+	fmt.Printf("Flaky test runs: %d\n", all.Flakes)
+	fmt.Printf("Timed out test runs: %d\n", all.Timeouts)
 	fmt.Printf("Test Pass Percentage: %0.2f\n", all.TestPassPercentage)
 
 	fmt.Println("\n\n================== Top 10 Most Frequently Failing Tests ==================")
 	count := 0
 	for i := 0; count < 10 && i < len(all.TestResults); i++ {
 		test := all.TestResults[i]
-		if !util.IgnoreTestRegex.MatchString(test.Name) && (test.Successes+test.Failures) > a.Options.MinTestRuns {
+		if !a.rules().IgnoreTest(test.Name) && (test.Successes+test.Failures) > a.Options.MinTestRuns {
 			fmt.Printf("Test Name: %s\n", test.Name)
-			fmt.Printf("Test Pass Percentage: %0.2f (%d runs)\n", test.PassPercentage, test.Successes+test.Failures)
+			fmt.Printf("Test Pass Percentage: %0.2f (%d runs, %d flakes, %d timeouts)\n", test.PassPercentage, test.Successes+test.Failures, test.Flakes, test.Timeouts)
 			if test.Successes+test.Failures < 10 {
 				fmt.Printf("WARNING: Only %d runs for this test\n", test.Successes+test.Failures)
 			}
@@ -465,6 +607,31 @@ func (a *Analyzer) printDashboardReport() {
 		}
 	}
 
+	fmt.Println("\n\n================== Top 10 Flakiest Tests ==================")
+	for i, test := range a.Report.Flakes {
+		if i == 10 {
+			break
+		}
+		fmt.Printf("Test Name: %s\n", test.Name)
+		fmt.Printf("Test Flake Percentage: %0.2f (%d flakes, %d runs failed then passed on the next run, %d runs)\n", test.FlakePercentage, test.Flakes, test.Flips, test.Successes+test.Failures)
+		fmt.Printf("Test Pass Percentage: %0.2f\n", test.PassPercentage)
+		fmt.Printf("\n")
+	}
+
+	fmt.Println("\n\n================== Known Issues ==================")
+	for _, status := range a.Report.KnownIssues {
+		fmt.Printf("Known Issue %d: %s\n", status.ID, status.Pattern)
+		fmt.Printf("Bug: %s, expires %s\n", status.BugURL(), status.Expires)
+		fmt.Printf("Failing Tests: %d\n", len(status.Tests))
+		if status.BugClosed {
+			fmt.Printf("WARNING: The bug of this issue is closed\n")
+		}
+		if status.Expired {
+			fmt.Printf("WARNING: This issue has expired\n")
+		}
+		fmt.Printf("\n")
+	}
+
 	fmt.Println("\n\n================== Top 10 Most Frequently Failing Jobs ==================")
 	jobRunsByName := util.SummarizeJobsByName(a.Report)
 
@@ -491,15 +658,16 @@ func (a *Analyzer) printDashboardReport() {
 		fmt.Printf("No clustered test failures observed")
 	}
 
-	fmt.Println("\n\n================== Summary By Platform ==================")
-	jobsByPlatform := util.SummarizeJobsByPlatform(a.Report)
-	for _, v := range jobsByPlatform {
-		fmt.Printf("Platform: %s\n", v.Platform)
-		fmt.Printf("Platform Job Pass Percentage: %0.2f%% (%d runs)\n", util.Percent(v.Successes, v.Failures), v.Successes+v.Failures)
-		if v.Successes+v.Failures < 10 {
-			fmt.Printf("WARNING: Only %d runs for this job\n", v.Successes+v.Failures)
+	for _, dimension := range a.rules().VariantDimensions() {
+		fmt.Printf("\n\n================== Summary By Variant: %s ==================\n", dimension)
+		for _, v := range util.SummarizeJobsByVariant(a.Report, dimension) {
+			fmt.Printf("Variant: %s\n", util.VariantName(dimension, v.Variant))
+			fmt.Printf("Variant Job Pass Percentage: %0.2f%% (%d runs)\n", util.Percent(v.Successes, v.Failures), v.Successes+v.Failures)
+			if v.Successes+v.Failures < 10 {
+				fmt.Printf("WARNING: Only %d runs for this job\n", v.Successes+v.Failures)
+			}
+			fmt.Printf("\n")
 		}
-		fmt.Printf("\n")
 	}
 }
 
@@ -508,33 +676,45 @@ func (a *Analyzer) printTextReport() {
 	all := a.Report.All["all"]
 	fmt.Printf("Passing test runs: %d\n", all.Successes)
 	fmt.Printf("Failing test runs: %d\n", all.Failures)
+	fmt.Printf("Flaky test runs: %d\n", all.Flakes)
+	fmt.Printf("Timed out test runs: %d\n", all.Timeouts)
 	fmt.Printf("Test Pass Percentage: %0.2f\n", all.TestPassPercentage)
 	testCount := 0
 	testSuccesses := 0
 	testFailures := 0
+	testFlakes := 0
+	testTimeouts := 0
 	for _, test := range all.TestResults {
 		fmt.Printf("\tTest Name: %s\n", test.Name)
 		fmt.Printf("\tPassed: %d\n", test.Successes)
 		fmt.Printf("\tFailed: %d\n", test.Failures)
-		fmt.Printf("\tTest Pass Percentage: %0.2f\n\n", test.PassPercentage)
+		fmt.Printf("\tFlaked: %d\n", test.Flakes)
+		fmt.Printf("\tTimed out: %d\n", test.Timeouts)
+		fmt.Printf("\tFailed then passed on the next run: %d\n", test.Flips)
+		fmt.Printf("\tTest Pass Percentage: %0.2f\n", test.PassPercentage)
+		fmt.Printf("\tTest Flake Percentage: %0.2f\n\n", test.FlakePercentage)
 		testCount++
 		testSuccesses += test.Successes
 		testFailures += test.Failures
+		testFlakes += test.Flakes
+		testTimeouts += test.Timeouts
 	}
 
-	fmt.Println("\n\n\n================== Test Summary By Platform ==================")
-	for key, by := range a.Report.ByPlatform {
-		fmt.Printf("Platform: %s\n", key)
-		//		fmt.Printf("Passing test runs: %d\n", platform.Successes)
-		//		fmt.Printf("Failing test runs: %d\n", platform.Failures)
-		fmt.Printf("Test Pass Percentage: %0.2f\n", by.TestPassPercentage)
-		for _, test := range by.TestResults {
-			fmt.Printf("\tTest Name: %s\n", test.Name)
-			fmt.Printf("\tPassed: %d\n", test.Successes)
-			fmt.Printf("\tFailed: %d\n", test.Failures)
-			fmt.Printf("\tTest Pass Percentage: %0.2f\n\n", test.PassPercentage)
+	for _, dimension := range a.rules().VariantDimensions() {
+		fmt.Printf("\n\n\n================== Test Summary By Variant: %s ==================\n", dimension)
+		for key, by := range a.Report.ByVariant[dimension] {
+			fmt.Printf("Variant: %s\n", util.VariantName(dimension, key))
+			fmt.Printf("Test Pass Percentage: %0.2f\n", by.TestPassPercentage)
+			for _, test := range by.TestResults {
+				fmt.Printf("\tTest Name: %s\n", test.Name)
+				fmt.Printf("\tPassed: %d\n", test.Successes)
+				fmt.Printf("\tFailed: %d\n", test.Failures)
+				fmt.Printf("\tFlaked: %d\n", test.Flakes)
+				fmt.Printf("\tTimed out: %d\n", test.Timeouts)
+				fmt.Printf("\tTest Pass Percentage: %0.2f\n\n", test.PassPercentage)
+			}
+			fmt.Println("")
 		}
-		fmt.Println("")
 	}
 
 	fmt.Println("\n\n\n================== Test Summary By Job ==================")
@@ -547,6 +727,8 @@ func (a *Analyzer) printTextReport() {
 			fmt.Printf("\tTest Name: %s\n", test.Name)
 			fmt.Printf("\tPassed: %d\n", test.Successes)
 			fmt.Printf("\tFailed: %d\n", test.Failures)
+			fmt.Printf("\tFlaked: %d\n", test.Flakes)
+			fmt.Printf("\tTimed out: %d\n", test.Timeouts)
 			fmt.Printf("\tTest Pass Percentage: %0.2f\n\n", test.PassPercentage)
 		}
 		fmt.Println("")
@@ -567,6 +749,18 @@ func (a *Analyzer) printTextReport() {
 		fmt.Println("")
 	}
 
+	fmt.Println("\n\n\n================== Test Summary By Team ==================")
+	for key, by := range a.Report.ByTeam {
+		fmt.Printf("\nTeam: %s\n", key)
+		fmt.Printf("Test Pass Percentage: %0.2f\n", by.TestPassPercentage)
+		for _, test := range by.TestResults {
+			fmt.Printf("\tTest Name: %s\n", test.Name)
+			fmt.Printf("\tComponent: %s\n", test.Owner.Component)
+			fmt.Printf("\tTest Pass Percentage: %0.2f\n\n", test.PassPercentage)
+		}
+		fmt.Println("")
+	}
+
 	fmt.Println("\n\n\n================== Clustered Test Failures ==================")
 	for _, group := range a.Report.FailureGroups {
 		fmt.Printf("Job url: %s\n", group.Url)
@@ -588,17 +782,18 @@ func (a *Analyzer) printTextReport() {
 		jobCount++
 	}
 
-	fmt.Println("\n\n================== Job Summary By Platform ==================")
-	jobsByPlatform := util.SummarizeJobsByPlatform(a.Report)
-	for _, v := range jobsByPlatform {
-		fmt.Printf("Platform: %s\n", v.Platform)
-		fmt.Printf("Job Succeses: %d\n", v.Successes)
-		fmt.Printf("Job Failures: %d\n", v.Failures)
-		fmt.Printf("Platform Job Pass Percentage: %0.2f%% (%d runs)\n", util.Percent(v.Successes, v.Failures), v.Successes+v.Failures)
-		if v.Successes+v.Failures < 10 {
-			fmt.Printf("WARNING: Only %d runs for this job\n", v.Successes+v.Failures)
+	for _, dimension := range a.rules().VariantDimensions() {
+		fmt.Printf("\n\n================== Job Summary By Variant: %s ==================\n", dimension)
+		for _, v := range util.SummarizeJobsByVariant(a.Report, dimension) {
+			fmt.Printf("Variant: %s\n", util.VariantName(dimension, v.Variant))
+			fmt.Printf("Job Succeses: %d\n", v.Successes)
+			fmt.Printf("Job Failures: %d\n", v.Failures)
+			fmt.Printf("Variant Job Pass Percentage: %0.2f%% (%d runs)\n", util.Percent(v.Successes, v.Failures), v.Successes+v.Failures)
+			if v.Successes+v.Failures < 10 {
+				fmt.Printf("WARNING: Only %d runs for this job\n", v.Successes+v.Failures)
+			}
+			fmt.Printf("\n")
 		}
-		fmt.Printf("\n")
 	}
 
 	fmt.Println("")
@@ -612,160 +807,909 @@ func (a *Analyzer) printTextReport() {
 	fmt.Printf("Total Tests: %d\n", testCount)
 	fmt.Printf("Total Test Successes: %d\n", testSuccesses)
 	fmt.Printf("Total Test Failures: %d\n", testFailures)
+	fmt.Printf("Total Test Flakes: %d\n", testFlakes)
+	fmt.Printf("Total Test Timeouts: %d\n", testTimeouts)
 	fmt.Printf("Total Test Pass Percentage: %0.2f\n", util.Percent(testSuccesses, testFailures))
 }
 
-type Server struct {
-	analyzers map[string]Analyzer
-	options   *Options
-}
+// newBaselineAnalyzer analyzes the named snapshot in the historical data directory.  The analysis window is
+// counted back from the newest run in the snapshot rather than from now, so a 7 day window covers the last week
+// before the snapshot was taken.
+func newBaselineAnalyzer(name string, o *Options) (Analyzer, error) {
+	release := util.ReleaseFromName(name)
+	if len(release) == 0 {
+		return Analyzer{}, fmt.Errorf("unable to determine the release of baseline %s", name)
+	}
+	path, err := snapshot.Resolve(filepath.Join(o.HistoricalData, name), "")
+	if err != nil {
+		return Analyzer{}, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return Analyzer{}, fmt.Errorf("Could not read baseline %s: %v", name, err)
+	}
 
-func (s *Server) refresh(w http.ResponseWriter, req *http.Request) {
-	klog.Infof("Refreshing data")
-	for k, analyzer := range s.analyzers {
-		analyzer.RawData = RawData{
-			ByAll:         make(map[string]util.AggregateTestResult),
-			ByJob:         make(map[string]util.AggregateTestResult),
-			ByPlatform:    make(map[string]util.AggregateTestResult),
-			BySig:         make(map[string]util.AggregateTestResult),
-			FailureGroups: make(map[string]util.JobRunResult),
+	analyzer := newAnalyzer(release, o)
+	analyzer.loadData([]string{release}, testgrid.NewLocalDataSource(path, o.TestGridURL))
+	if len(analyzer.RawData.JobDetails) == 0 {
+		return Analyzer{}, fmt.Errorf("no data for release %s in baseline %s", release, name)
+	}
+	for _, details := range analyzer.RawData.JobDetails {
+		if len(details.Timestamps) == 0 {
+			continue
+		}
+		// timestamps are in milliseconds, newest first
+		if t := time.Unix(int64(details.Timestamps[0]/1000), 0); t.After(analyzer.EndTime) {
+			analyzer.EndTime = t
 		}
-
-		analyzer.loadData([]string{analyzer.Release}, analyzer.Options.LocalData)
-		analyzer.analyze()
-		analyzer.prepareTestReport(strings.Contains(k, "-prev"))
-		s.analyzers[k] = analyzer
 	}
-
-	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
-	w.WriteHeader(http.StatusOK)
-	klog.Infof("Refresh complete")
+	analyzer.analyze()
+	analyzer.prepareTestReport(true)
+	return analyzer, nil
 }
 
-func (s *Server) printHtmlReport(w http.ResponseWriter, req *http.Request) {
-
-	release := req.URL.Query().Get("release")
-	if _, ok := s.analyzers[release]; !ok {
-		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprintf(w, "Invalid release identifier: %s", release)
-		return
+// loadBaselines analyzes every snapshot in the historical data directory.  Snapshots that cannot be analyzed are
+// logged and skipped.
+func loadBaselines(o *Options) map[string]Analyzer {
+	baselines := make(map[string]Analyzer)
+	entries, err := ioutil.ReadDir(o.HistoricalData)
+	if err != nil {
+		klog.V(2).Infof("No historical data loaded: %v\n", err)
+		return baselines
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		baseline, err := newBaselineAnalyzer(entry.Name(), o)
+		if err != nil {
+			klog.Errorf("Error loading baseline %s: %v\n", entry.Name(), err)
+			continue
+		}
+		baselines[entry.Name()] = baseline
 	}
-	html.PrintHtmlReport(w, req, s.analyzers[release].Report, s.analyzers[release+"-prev"].Report, s.options.EndDay, 15)
+	return baselines
 }
 
-func (s *Server) detailed(w http.ResponseWriter, req *http.Request) {
+func (a *Analyzer) compareToBaseline(baseline Analyzer, name string) util.BaselineComparison {
+	return util.CompareToBaseline(a.Report, baseline.Report, a.RawData.ByAll["all"], baseline.RawData.ByAll["all"], name, a.rules(), a.Options.MinTestRuns)
+}
 
-	release := "4.5"
-	t := req.URL.Query().Get("release")
-	if t != "" {
-		release = t
+func printBaselineComparison(comparison util.BaselineComparison, output string) {
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.Encode(comparison)
+		return
 	}
 
-	startDay := 0
-	t = req.URL.Query().Get("startDay")
-	if t != "" {
-		startDay, _ = strconv.Atoi(t)
+	printComparisons := func(title string, comparisons []util.Comparison, count int) {
+		fmt.Printf("\n\n================== %s ==================\n", title)
+		for i, c := range comparisons {
+			if i == count {
+				fmt.Printf("Plus %d more\n", len(comparisons)-count)
+				break
+			}
+			fmt.Printf("%s\n", c.Name)
+			fmt.Printf("\tPass Percentage: %0.2f%% (%d runs)\n", c.Current.PassPercentage, c.Current.Runs())
+			if c.Baseline == nil {
+				fmt.Printf("\t%s Pass Percentage: NA\n\n", comparison.Baseline)
+				continue
+			}
+			fmt.Printf("\t%s Pass Percentage: %0.2f%% (%d runs)\n", comparison.Baseline, c.Baseline.PassPercentage, c.Baseline.Runs())
+			fmt.Printf("\tChange: %+0.2f%%\n\n", c.Delta())
+		}
 	}
 
-	endDay := 7
-	t = req.URL.Query().Get("endDay")
-	if t != "" {
-		endDay, _ = strconv.Atoi(t)
-	}
+	fmt.Printf("================== %s Compared To %s ==================\n", comparison.Release, comparison.Baseline)
+	printComparisons("Summary", []util.Comparison{comparison.TestPassRate, comparison.JobPassRate}, -1)
+	printComparisons("Job Pass Rates By Variant", comparison.Variants, -1)
+	printComparisons("Job Pass Rates By Job Name", comparison.Jobs, -1)
+	printComparisons("Top Test Regressions", comparison.Tests, 50)
+}
 
-	testSuccessThreshold := 98.0
-	t = req.URL.Query().Get("testSuccessThreshold")
-	if t != "" {
-		testSuccessThreshold, _ = strconv.ParseFloat(t, 64)
+func printReleaseComparison(comparison util.ReleaseComparison, output string) {
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.Encode(comparison)
+		return
 	}
 
-	jobFilter := ""
-	t = req.URL.Query().Get("jobFilter")
-	if t != "" {
-		jobFilter = t
+	printRows := func(title string, rows []util.ReleaseRow, count int) {
+		fmt.Printf("\n\n================== %s ==================\n", title)
+		for i, row := range rows {
+			if i == count {
+				fmt.Printf("Plus %d more\n", len(rows)-count)
+				break
+			}
+			fmt.Printf("%s\n", row.Name)
+			for j, p := range row.PassRates {
+				if p == nil {
+					fmt.Printf("\t%s Pass Percentage: NA\n", comparison.Releases[j])
+					continue
+				}
+				fmt.Printf("\t%s Pass Percentage: %0.2f%% (%d runs)\n", comparison.Releases[j], p.PassPercentage, p.Runs())
+			}
+			if row.Delta != nil {
+				fmt.Printf("\tChange: %+0.2f%%\n", *row.Delta)
+			}
+			fmt.Println()
+		}
 	}
 
-	minTestRuns := 10
-	t = req.URL.Query().Get("minTestRuns")
-	if t != "" {
-		minTestRuns, _ = strconv.Atoi(t)
-	}
+	fmt.Printf("================== %s ==================\n", strings.Join(comparison.Releases, " vs "))
+	printRows("Job Pass Rates By Variant", comparison.Variants, -1)
+	printRows("Job Pass Rates By Job Name", comparison.Jobs, -1)
+	printRows("Test Pass Rates", comparison.Tests, 50)
+}
 
-	fct := 10
-	t = req.URL.Query().Get("failureClusterThreshold")
-	if t != "" {
-		fct, _ = strconv.Atoi(t)
-	}
+type Server struct {
+	// lock guards analyzers, source and reports.  They are replaced as a whole when the data is refreshed and never
+	// modified in place, so a request holds on to the ones it started with even if a refresh completes part way
+	// through.
+	lock      sync.RWMutex
+	analyzers map[string]Analyzer
+	source    testgrid.DataSource
+	// reports caches the analyzers computed for /detailed, keyed by detailedKey.
+	reports *cache.LRU
+	// baselines are the analyzers for each historical snapshot, keyed by snapshot name.
+	baselines map[string]Analyzer
+	// options are the options the data is analyzed with, they are also guarded by lock and replaced when the
+	// configuration file is reloaded.  flags are the options from the command line the configuration is applied to.
+	options *Options
+	flags   *Options
+
+	statusLock sync.Mutex
+	status     RefreshStatus
+}
 
-	jobTestCount := math.MaxInt32
-	t = req.URL.Query().Get("jobTestCount")
-	if t != "" {
-		jobTestCount, _ = strconv.Atoi(t)
-	}
+// RefreshStatus reports the progress of background refreshes of the data.
+type RefreshStatus struct {
+	// Refreshing is true while a refresh is running, Pending is true if another refresh was requested while it was
+	// and will start when it completes.
+	Refreshing    bool      `json:"refreshing"`
+	Pending       bool      `json:"pending"`
+	LastStarted   time.Time `json:"lastStarted"`
+	LastCompleted time.Time `json:"lastCompleted"`
+	// Errors are the problems found by the last completed refresh.  Releases that could not be loaded keep their
+	// previous data.
+	Errors []string `json:"errors,omitempty"`
+	// NextFetch is when the scheduler will next fetch new data, it is only set when --fetch-interval is.
+	NextFetch *time.Time `json:"nextFetch,omitempty"`
+	// Releases are the outcomes of the latest fetch and refresh of each release.
+	Releases map[string]ReleaseStatus `json:"releases"`
+}
 
-	opt := &Options{
-		StartDay:                startDay,
-		EndDay:                  endDay,
-		TestSuccessThreshold:    testSuccessThreshold,
-		JobFilter:               jobFilter,
-		MinTestRuns:             minTestRuns,
-		FailureClusterThreshold: fct,
+// ReleaseStatus reports when the data of a release was last fetched and refreshed, and the errors from the latest
+// attempts.  LastSuccess is the most recent successful refresh of the release that was not preceded by a failed
+// fetch.
+type ReleaseStatus struct {
+	LastFetched   time.Time `json:"lastFetched"`
+	LastRefreshed time.Time `json:"lastRefreshed"`
+	LastSuccess   time.Time `json:"lastSuccess"`
+	FetchError    string    `json:"fetchError,omitempty"`
+	RefreshError  string    `json:"refreshError,omitempty"`
+}
+
+// buildAnalyzers analyzes each release over the reported and comparison periods.  Releases with no data are
+// returned as errors.
+func buildAnalyzers(o *Options, source testgrid.DataSource) (map[string]Analyzer, map[string]error) {
+	analyzers := make(map[string]Analyzer)
+	errs := make(map[string]error)
+	for _, release := range o.Releases {
+		// the period being reported on, the most recent 7 days by default
+		analyzer := newAnalyzer(release, o)
+		analyzer.loadData([]string{release}, source)
+		if len(analyzer.RawData.JobDetails) == 0 {
+			errs[release] = fmt.Errorf("no job data found for release %s", release)
+			continue
+		}
+		analyzer.analyze()
+		analyzer.prepareTestReport(false)
+		analyzers[release] = analyzer
+
+		// the period it is compared to, the 7 days before that by default
+		analyzer = newAnalyzer(release, o.compareOptions())
+		analyzer.loadData([]string{release}, source)
+		analyzer.analyze()
+		analyzer.prepareTestReport(true)
+		analyzers[release+"-prev"] = analyzer
 	}
+	return analyzers, errs
+}
 
-	analyzer := Analyzer{
-		Release: release,
-		Options: opt,
-		RawData: RawData{
-			ByAll:         make(map[string]util.AggregateTestResult),
-			ByJob:         make(map[string]util.AggregateTestResult),
-			ByPlatform:    make(map[string]util.AggregateTestResult),
-			BySig:         make(map[string]util.AggregateTestResult),
-			FailureGroups: make(map[string]util.JobRunResult),
-		},
+// current returns the analyzers and data source to serve a request from.
+func (s *Server) current() (map[string]Analyzer, testgrid.DataSource) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.analyzers, s.source
+}
+
+// currentOptions returns the options the current data was analyzed with.
+func (s *Server) currentOptions() *Options {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.options
+}
+
+// currentReports returns the data source and the cache of /detailed analyses of it.
+func (s *Server) currentReports() (testgrid.DataSource, *cache.LRU) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.source, s.reports
+}
+
+func (s *Server) refreshStatus() RefreshStatus {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	status := s.status
+	status.Releases = make(map[string]ReleaseStatus, len(s.status.Releases))
+	for release, releaseStatus := range s.status.Releases {
+		status.Releases[release] = releaseStatus
 	}
-	analyzer.loadData([]string{release}, s.options.LocalData)
-	analyzer.analyze()
-	analyzer.prepareTestReport(false)
+	return status
+}
 
-	// prior 7 day period
-	optCopy := *opt
-	optCopy.StartDay = endDay + 1
-	optCopy.EndDay = endDay + 8
-	prevAnalyzer := Analyzer{
-		Release: release,
-		Options: &optCopy,
-		RawData: RawData{
-			ByAll:         make(map[string]util.AggregateTestResult),
-			ByJob:         make(map[string]util.AggregateTestResult),
-			ByPlatform:    make(map[string]util.AggregateTestResult),
-			BySig:         make(map[string]util.AggregateTestResult),
-			FailureGroups: make(map[string]util.JobRunResult),
-		},
+// updateReleaseStatus applies update to the status of each release, with the status lock held.
+func (s *Server) updateReleaseStatus(update func(release string, status *ReleaseStatus)) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	if s.status.Releases == nil {
+		s.status.Releases = make(map[string]ReleaseStatus)
+	}
+	for _, release := range s.currentOptions().Releases {
+		status := s.status.Releases[release]
+		update(release, &status)
+		s.status.Releases[release] = status
+	}
+}
+
+// requestRefresh starts a refresh in the background.  If one is already running, another is queued to start when
+// it completes, so the data read is never older than the request.  Any number of requests made while a refresh is
+// running are served by the one queued refresh.
+func (s *Server) requestRefresh() {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	if s.status.Refreshing {
+		s.status.Pending = true
+		return
+	}
+	s.status.Refreshing = true
+	go s.refreshLoop()
+}
+
+func (s *Server) refreshLoop() {
+	for {
+		s.reload()
+
+		s.statusLock.Lock()
+		if !s.status.Pending {
+			s.status.Refreshing = false
+			s.statusLock.Unlock()
+			return
+		}
+		s.status.Pending = false
+		s.statusLock.Unlock()
+	}
+}
+
+// reload rebuilds every analyzer from the newest data and swaps them in.
+func (s *Server) reload() {
+	klog.Infof("Refreshing data")
+	s.statusLock.Lock()
+	s.status.LastStarted = time.Now()
+	s.statusLock.Unlock()
+
+	errs := []string{}
+	defer func() {
+		s.statusLock.Lock()
+		s.status.LastCompleted = time.Now()
+		s.status.Errors = errs
+		s.statusLock.Unlock()
+	}()
+
+	o := s.currentOptions()
+	if len(s.flags.ConfigFile) > 0 {
+		if reloaded, err := s.flags.withConfig(); err != nil {
+			klog.Errorf("Error reloading the configuration, continuing with the previous one: %v", err)
+			errs = append(errs, err.Error())
+		} else if err := reloaded.compileRules(); err != nil {
+			klog.Errorf("Error reloading the configuration, continuing with the previous one: %v", err)
+			errs = append(errs, err.Error())
+		} else {
+			if reloaded.KnownIssuesFile != o.KnownIssues.Path() {
+				err := fmt.Errorf("the known issues file changed from %q to %q, sippy must be restarted to use it", o.KnownIssues.Path(), reloaded.KnownIssuesFile)
+				klog.Errorf("Error reloading the configuration, continuing with the previous known issues file: %v", err)
+				errs = append(errs, err.Error())
+				reloaded.KnownIssuesFile = o.KnownIssues.Path()
+			}
+			o = reloaded
+		}
+	}
+	if err := o.KnownIssues.Reload(); err != nil {
+		klog.Errorf("Error reloading the known issues, continuing with the previous ones: %v", err)
+		errs = append(errs, err.Error())
+	}
+	// the current options are still used by the requests being served, so the bugs are looked up into a copy
+	optCopy := *o
+	o = &optCopy
+	if err := o.findBugStatuses(); err != nil {
+		klog.Errorf("Error refreshing the known issues, their bugs have no status: %v", err)
+		errs = append(errs, err.Error())
+	}
+
+	// pick up the newest complete snapshot
+	source, err := o.cachingDataSource()
+	if err != nil {
+		klog.Errorf("Error resolving data source, continuing with the previous data: %v", err)
+		errs = append(errs, fmt.Sprintf("unable to resolve data source: %v", err))
+		s.updateReleaseStatus(func(release string, status *ReleaseStatus) {
+			status.RefreshError = errs[len(errs)-1]
+		})
+		return
+	}
+	analyzers, releaseErrs := buildAnalyzers(o, source)
+	refreshed := time.Now()
+	s.updateReleaseStatus(func(release string, status *ReleaseStatus) {
+		if err, ok := releaseErrs[release]; ok {
+			status.RefreshError = err.Error()
+			return
+		}
+		status.LastRefreshed = refreshed
+		status.RefreshError = ""
+		if len(status.FetchError) == 0 {
+			status.LastSuccess = refreshed
+		}
+	})
+
+	previous, _ := s.current()
+	for release, err := range releaseErrs {
+		klog.Errorf("Error refreshing release %s, continuing with the previous data: %v", release, err)
+		errs = append(errs, err.Error())
+		for _, k := range []string{release, release + "-prev"} {
+			if analyzer, ok := previous[k]; ok {
+				analyzers[k] = analyzer
+			}
+		}
+	}
+	sort.Strings(errs)
+
+	s.lock.Lock()
+	previousSource := s.source
+	s.analyzers = analyzers
+	s.source = source
+	s.options = o
+	s.reports = cache.NewLRU(o.ReportCacheSize)
+	// the reports are rendered with the rules they were analyzed with
+	util.SetRules(o.Rules)
+	s.lock.Unlock()
+	closeDataSource(previousSource)
+	klog.Infof("Refresh complete")
+}
+
+// fetchLoop fetches new data into the --local-data directory every --fetch-interval, after waiting
+// --fetch-initial-delay so that a server that is restarting repeatedly doesn't query testgrid every time, and
+// refreshes the server from each new snapshot.
+func (s *Server) fetchLoop(ctx context.Context) {
+	next := time.Now().Add(s.currentOptions().FetchInitialDelay)
+	for {
+		s.statusLock.Lock()
+		s.status.NextFetch = &next
+		s.statusLock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		// without a new snapshot there is nothing new to refresh from
+		if s.fetch(ctx) {
+			s.requestRefresh()
+		}
+		// the interval is read again after every fetch, so a reloaded configuration can change or stop it
+		interval := s.currentOptions().FetchInterval
+		if interval <= 0 {
+			klog.Infof("Scheduled fetches were disabled by the configuration")
+			s.statusLock.Lock()
+			s.status.NextFetch = nil
+			s.statusLock.Unlock()
+			return
+		}
+		next = time.Now().Add(interval)
+	}
+}
+
+// fetch downloads a new snapshot and records the outcome for each release, and returns true if the snapshot was
+// completed.  A release whose downloads failed is still refreshed from the new snapshot, which has all the data that
+// could be fetched for it.
+func (s *Server) fetch(ctx context.Context) bool {
+	klog.Infof("Fetching new data")
+	o := s.currentOptions()
+	summary, err := downloadData(ctx, o.Releases, releaseDashboards(o.Dashboards, o.Releases), o.JobFilter, o.TestGridURL, o.FetchOptions, o.LocalData, o.KeepSnapshots, o.Database)
+	if err != nil {
+		klog.Errorf("Error fetching data, continuing with the previous data: %v", err)
+	}
+	fetched := time.Now()
+	s.updateReleaseStatus(func(release string, status *ReleaseStatus) {
+		if err != nil {
+			status.FetchError = err.Error()
+			return
+		}
+		dashboards := make(map[string]bool)
+		for _, dashboard := range releaseDashboards(o.Dashboards, []string{release}) {
+			dashboards[dashboard] = true
+		}
+		failures := 0
+		for _, failure := range summary.Failures {
+			if dashboards[failure.Dashboard] {
+				failures++
+			}
+		}
+		status.LastFetched = fetched
+		status.FetchError = ""
+		if failures > 0 {
+			status.FetchError = fmt.Sprintf("failed to fetch %d dashboards and jobs", failures)
+		}
+	})
+	klog.Infof("Fetch complete")
+	return err == nil
+}
+
+// refresh starts a background refresh of the data and responds with the refresh status.  /refresh/status only
+// reports the status.
+func (s *Server) refresh(w http.ResponseWriter, req *http.Request) {
+	code := http.StatusOK
+	if req.URL.Path != "/refresh/status" {
+		s.requestRefresh()
+		code = http.StatusAccepted
+	}
+	api.WriteJSON(w, code, s.refreshStatus())
+}
+
+func (s *Server) printHtmlReport(w http.ResponseWriter, req *http.Request) {
+
+	analyzers, _ := s.current()
+	release := req.URL.Query().Get("release")
+	analyzer, ok := analyzers[release]
+	if !ok {
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Invalid release identifier: %s", gohtml.EscapeString(release))
+		return
+	}
+	html.PrintHtmlReport(w, req, analyzer.Report, analyzers[release+"-prev"].Report, analyzer.RawData.Trends, analyzer.rules(), 15, reportSettings(release, s.currentOptions()))
+}
+
+// maxDetailedDays is how far back /detailed can look.
+const maxDetailedDays = 365
+
+// parseDetailedOptions parses the /detailed query parameters into the options for the report and the number of
+// tests to list for each job.  Parameters that are not set take their defaults.
+func parseDetailedOptions(req *http.Request) (*Options, int, error) {
+	opt := &Options{JobFilter: req.URL.Query().Get("jobFilter")}
+	if _, err := regexp.Compile(opt.JobFilter); err != nil {
+		return nil, 0, &api.ParamError{Param: "jobFilter", Value: opt.JobFilter, Reason: err.Error()}
+	}
+
+	var err error
+	if opt.StartDay, err = api.IntParam(req, "startDay", 0, 0, maxDetailedDays-1); err != nil {
+		return nil, 0, err
+	}
+	// the end day defaults to 7, or a week after the start day if that is later
+	endDay := 7
+	if opt.StartDay >= endDay {
+		endDay = opt.StartDay + 7
+	}
+	if opt.EndDay, err = api.IntParam(req, "endDay", endDay, opt.StartDay+1, maxDetailedDays); err != nil {
+		return nil, 0, err
+	}
+	// the comparison defaults to the period of the same length immediately before the one being reported on
+	if opt.CompareStartDay, err = api.IntParam(req, "compareStartDay", -1, -1, maxDetailedDays-1); err != nil {
+		return nil, 0, err
+	}
+	if opt.CompareEndDay, err = api.IntParam(req, "compareEndDay", -1, -1, maxDetailedDays); err != nil {
+		return nil, 0, err
+	}
+	if compare := opt.compareOptions(); compare.EndDay <= compare.StartDay {
+		return nil, 0, &api.ParamError{Param: "compareEndDay", Value: strconv.Itoa(compare.EndDay), Reason: fmt.Sprintf("must be greater than the comparison start day %d", compare.StartDay)}
+	}
+	if opt.TestSuccessThreshold, err = api.FloatParam(req, "testSuccessThreshold", 98, 0, 100); err != nil {
+		return nil, 0, err
+	}
+	if opt.MinTestRuns, err = api.IntParam(req, "minTestRuns", 10, 0, math.MaxInt32); err != nil {
+		return nil, 0, err
+	}
+	if opt.TrendBucketDays, err = api.IntParam(req, "trendBucketDays", 1, 1, opt.EndDay-opt.StartDay); err != nil {
+		return nil, 0, err
+	}
+	if opt.FailureClusterThreshold, err = api.IntParam(req, "failureClusterThreshold", 10, -1, math.MaxInt32); err != nil {
+		return nil, 0, err
+	}
+	jobTestCount, err := api.IntParam(req, "jobTestCount", math.MaxInt32, 0, math.MaxInt32)
+	if err != nil {
+		return nil, 0, err
+	}
+	return opt, jobTestCount, nil
+}
+
+// reportSettings describes the options a report was generated with.
+func reportSettings(release string, o *Options) []html.Setting {
+	compare := o.compareOptions()
+	settings := []html.Setting{
+		{Name: "release", Value: release},
+		{Name: "days", Value: fmt.Sprintf("%d-%d", o.StartDay, o.EndDay)},
+		{Name: "compared to days", Value: fmt.Sprintf("%d-%d", compare.StartDay, compare.EndDay)},
+		{Name: "test success threshold", Value: fmt.Sprintf("%g%%", o.TestSuccessThreshold)},
+		{Name: "min test runs", Value: strconv.Itoa(o.MinTestRuns)},
+		{Name: "failure cluster threshold", Value: strconv.Itoa(o.FailureClusterThreshold)},
+		{Name: "trend bucket days", Value: strconv.Itoa(o.TrendBucketDays)},
+	}
+	if len(o.JobFilter) > 0 {
+		settings = append(settings, html.Setting{Name: "job filter", Value: o.JobFilter})
+	}
+	return settings
+}
+
+// detailed serves a report generated with the options in the query parameters.  Invalid parameters are rejected
+// with a json api.Error naming the parameter.
+func (s *Server) detailed(w http.ResponseWriter, req *http.Request) {
+	options := s.currentOptions()
+	release := req.URL.Query().Get("release")
+	found := false
+	for _, r := range options.Releases {
+		found = found || r == release
+	}
+	if !found {
+		api.WriteBadRequest(w, &api.ParamError{Param: "release", Value: release, Reason: fmt.Sprintf("must be one of %v", options.Releases)})
+		return
+	}
+	opt, jobTestCount, err := parseDetailedOptions(req)
+	if err != nil {
+		api.WriteBadRequest(w, err)
+		return
+	}
+	opt.Dashboards, opt.ProwURL, opt.KnownIssues, opt.Rules = options.Dashboards, options.ProwURL, options.KnownIssues, options.Rules
+	opt.BugStatuses = options.BugStatuses
+
+	source, reports := s.currentReports()
+	key := detailedKey(release, opt)
+	var analyzer, prevAnalyzer Analyzer
+	if cached, ok := reports.Get(key); ok {
+		analyzers := cached.([2]Analyzer)
+		analyzer, prevAnalyzer = analyzers[0], analyzers[1]
+	} else {
+		analyzer = newAnalyzer(release, opt)
+		analyzer.loadData([]string{release}, source)
+		analyzer.analyze()
+		analyzer.prepareTestReport(false)
+
+		prevAnalyzer = newAnalyzer(release, opt.compareOptions())
+		prevAnalyzer.loadData([]string{release}, source)
+		prevAnalyzer.analyze()
+		prevAnalyzer.prepareTestReport(true)
+		reports.Add(key, [2]Analyzer{analyzer, prevAnalyzer})
+	}
+
+	html.PrintHtmlReport(w, req, analyzer.Report, prevAnalyzer.Report, analyzer.RawData.Trends, analyzer.rules(), jobTestCount, reportSettings(release, opt))
+
+}
+
+// detailedKey identifies a /detailed analysis.  The comparison period is resolved first so that requests for the
+// same periods share an analysis however they were specified.
+func detailedKey(release string, o *Options) string {
+	compare := o.compareOptions()
+	return fmt.Sprintf("%s|%d|%d|%d|%d|%g|%q|%d|%d|%d", release, o.StartDay, o.EndDay, compare.StartDay, compare.EndDay,
+		o.TestSuccessThreshold, o.JobFilter, o.MinTestRuns, o.FailureClusterThreshold, o.TrendBucketDays)
+}
+
+// trends serves the pass rate trends for a release as json.  The type parameter selects the tests, jobs or sigs
+// series, or the series of a variant dimension such as cloud, and name selects a single series.
+func (s *Server) trends(w http.ResponseWriter, req *http.Request) {
+	analyzers, _ := s.current()
+	release := req.URL.Query().Get("release")
+	analyzer, ok := analyzers[release]
+	if !ok {
+		api.WriteError(w, http.StatusNotFound, "invalid release %q, must be one of %v", release, s.Releases())
+		return
+	}
+	trends := analyzer.RawData.Trends
+
+	var result interface{} = trends
+	if kind := req.URL.Query().Get("type"); len(kind) != 0 {
+		series, ok := trends.Series(kind)
+		if !ok {
+			reason := "must be one of tests, jobs, sigs or a variant dimension: " + strings.Join(analyzer.rules().VariantDimensions(), ", ")
+			api.WriteBadRequest(w, &api.ParamError{Param: "type", Value: kind, Reason: reason})
+			return
+		}
+		result = series
+		if name := req.URL.Query().Get("name"); len(name) != 0 {
+			trend, ok := series[name]
+			if !ok {
+				api.WriteError(w, http.StatusNotFound, "no %s trend named %q", kind, name)
+				return
+			}
+			result = trend
+		}
+	}
+
+	api.WriteJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) baseline(w http.ResponseWriter, req *http.Request) {
+	analyzers, _ := s.current()
+	release := req.URL.Query().Get("release")
+	current, ok := analyzers[release]
+	if !ok {
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Invalid release identifier: %s", gohtml.EscapeString(release))
+		return
+	}
+
+	name := req.URL.Query().Get("baseline")
+	if len(name) == 0 {
+		name = s.defaultBaseline(release)
+	}
+	baseline, ok := s.baselines[name]
+	if !ok {
+		names := []string{}
+		for k := range s.baselines {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Invalid baseline identifier: %s, available baselines: %s", gohtml.EscapeString(name), gohtml.EscapeString(strings.Join(names, ", ")))
+		return
+	}
+
+	html.PrintBaselineReport(w, req, current.compareToBaseline(baseline, name), 50)
+}
+
+// defaultBaseline is the newest baseline taken from the release, e.g. 4.4GA rather than 4.4-rc1 for 4.4.  Baselines
+// with runs up to the same time are told apart by name.
+func (s *Server) defaultBaseline(release string) string {
+	name := ""
+	var newest time.Time
+	for k, baseline := range s.baselines {
+		if baseline.Release != release {
+			continue
+		}
+		if len(name) == 0 || baseline.EndTime.After(newest) || (baseline.EndTime.Equal(newest) && k > name) {
+			name, newest = k, baseline.EndTime
+		}
+	}
+	return name
+}
+
+// jobRun looks up the job run with the prow url in the reported and comparison periods of each release.
+func (s *Server) jobRun(url string) (util.JobRunSummary, time.Time, bool) {
+	analyzers, _ := s.current()
+	for _, release := range s.currentOptions().Releases {
+		for _, k := range []string{release, release + "-prev"} {
+			analyzer, ok := analyzers[k]
+			if !ok {
+				continue
+			}
+			if run, ok := analyzer.RawData.FailureGroups[url]; ok {
+				return util.SummarizeJobRun(release, run, analyzer.RawData.ByAll["all"], analyzer.Options.MinTestRuns), analyzer.Report.Timestamp, true
+			}
+		}
+	}
+	return util.JobRunSummary{}, time.Time{}, false
+}
+
+// run shows the tests that failed in the job run with the prow url given by the url parameter.
+func (s *Server) run(w http.ResponseWriter, req *http.Request) {
+	url := req.URL.Query().Get("url")
+	run, timestamp, ok := s.jobRun(url)
+	if !ok {
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "No job run found for url: %s", gohtml.EscapeString(url))
+		return
+	}
+	html.PrintRunReport(w, req, run, timestamp)
+}
+
+// testSummary breaks down the results of the test in the release.
+func (s *Server) testSummary(release, name string) (util.TestSummary, *Analyzer, bool) {
+	analyzers, _ := s.current()
+	analyzer, ok := analyzers[release]
+	if !ok {
+		return util.TestSummary{}, nil, false
+	}
+	summary, ok := util.SummarizeTest(name, release, analyzer.RawData.ByAll, analyzer.RawData.ByJob, analyzer.RawData.ByVariant, analyzer.RawData.FailureGroups, analyzer.rules(), 500)
+	if !ok {
+		return util.TestSummary{}, nil, false
+	}
+	for _, r := range s.currentOptions().Releases {
+		if result, ok := analyzers[r].RawData.ByAll["all"].TestResults[name]; ok {
+			summary.Releases = append(summary.Releases, util.NewTestBreakdown(r, result))
+		}
+	}
+	return summary, &analyzer, true
+}
+
+// test shows the results of the test given by the name parameter broken down by release, job and variant.
+func (s *Server) test(w http.ResponseWriter, req *http.Request) {
+	analyzers, _ := s.current()
+	release := req.URL.Query().Get("release")
+	if _, ok := analyzers[release]; !ok {
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Invalid release identifier: %s", gohtml.EscapeString(release))
+		return
+	}
+	name := req.URL.Query().Get("name")
+	summary, analyzer, ok := s.testSummary(release, name)
+	if !ok {
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "No results for test: %s", gohtml.EscapeString(name))
+		return
+	}
+	html.PrintTestReport(w, req, summary, analyzer.RawData.Trends.Tests[name], analyzer.Report.Timestamp)
+}
+
+// jobSummary lists the runs of the job in the release.
+func (s *Server) jobSummary(release, name string) (util.JobSummary, *Analyzer, bool) {
+	analyzers, _ := s.current()
+	analyzer, ok := analyzers[release]
+	if !ok {
+		return util.JobSummary{}, nil, false
+	}
+	prev := analyzers[release+"-prev"]
+	summary, ok := util.SummarizeJob(name, release, analyzer.RawData.FailureGroups, prev.RawData.FailureGroups, analyzer.RawData.ByJob,
+		analyzer.rules(), analyzer.Options.MinTestRuns, analyzer.Options.FailureClusterThreshold, 25)
+	if !ok {
+		return util.JobSummary{}, nil, false
+	}
+	return summary, &analyzer, true
+}
+
+// job shows the run history of the job given by the name parameter.
+func (s *Server) job(w http.ResponseWriter, req *http.Request) {
+	analyzers, _ := s.current()
+	release := req.URL.Query().Get("release")
+	if _, ok := analyzers[release]; !ok {
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Invalid release identifier: %s", gohtml.EscapeString(release))
+		return
+	}
+	name := req.URL.Query().Get("name")
+	summary, analyzer, ok := s.jobSummary(release, name)
+	if !ok {
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "No runs of job: %s", gohtml.EscapeString(name))
+		return
+	}
+	html.PrintJobReport(w, req, summary, analyzer.RawData.Trends.Jobs[name], analyzer.Report.Timestamp)
+}
+
+// compareReleases puts the releases side by side.  The analyzers of a refresh share their rules, so the releases are
+// compared with the rules of the first one.
+func compareReleases(analyzers []Analyzer, minRuns int) util.ReleaseComparison {
+	reports := []util.ReleaseReport{}
+	for _, analyzer := range analyzers {
+		reports = append(reports, util.ReleaseReport{Report: analyzer.Report, All: analyzer.RawData.ByAll["all"]})
+	}
+	rules := util.CurrentRules()
+	if len(analyzers) > 0 {
+		rules = analyzers[0].rules()
+	}
+	return util.CompareReleases(reports, rules, minRuns)
+}
+
+// compare shows the releases given by the release parameters side by side, all of the releases by default.
+func (s *Server) compare(w http.ResponseWriter, req *http.Request) {
+	releases := req.URL.Query()["release"]
+	if len(releases) == 0 {
+		releases = s.Releases()
+	}
+	analyzers, _ := s.current()
+	for _, release := range releases {
+		if _, ok := analyzers[release]; !ok {
+			w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "Invalid release identifier: %s", gohtml.EscapeString(release))
+			return
+		}
+	}
+	html.PrintReleasesReport(w, req, s.CompareReleases(releases), 50)
+}
+
+// Releases, Report, JobRun, Test, Job, CompareReleases, KnownIssues, KnownIssuesWritable and Refresh provide the
+// reports to the api.
+func (s *Server) Releases() []string {
+	analyzers, _ := s.current()
+	releases := []string{}
+	for _, release := range s.currentOptions().Releases {
+		if _, ok := analyzers[release]; ok {
+			releases = append(releases, release)
+		}
+	}
+	return releases
+}
+
+func (s *Server) Report(release string) (util.TestReport, bool) {
+	analyzers, _ := s.current()
+	analyzer, ok := analyzers[release]
+	return analyzer.Report, ok
+}
+
+func (s *Server) JobRun(url string) (util.JobRunSummary, bool) {
+	run, _, ok := s.jobRun(url)
+	return run, ok
+}
+
+func (s *Server) Test(release, name string) (util.TestSummary, bool) {
+	summary, _, ok := s.testSummary(release, name)
+	return summary, ok
+}
+
+func (s *Server) Job(release, name string) (util.JobSummary, bool) {
+	summary, _, ok := s.jobSummary(release, name)
+	return summary, ok
+}
+
+func (s *Server) CompareReleases(releases []string) util.ReleaseComparison {
+	analyzers, _ := s.current()
+	compared := []Analyzer{}
+	for _, release := range releases {
+		if analyzer, ok := analyzers[release]; ok {
+			compared = append(compared, analyzer)
+		}
 	}
-	prevAnalyzer.loadData([]string{release}, s.options.LocalData)
-	prevAnalyzer.analyze()
-	prevAnalyzer.prepareTestReport(true)
+	return compareReleases(compared, s.currentOptions().MinTestRuns)
+}
 
-	html.PrintHtmlReport(w, req, analyzer.Report, prevAnalyzer.Report, opt.EndDay, jobTestCount)
+func (s *Server) KnownIssues() *knownissues.Registry {
+	return s.flags.KnownIssues
+}
+
+func (s *Server) KnownIssuesWritable() bool {
+	return s.flags.KnownIssuesWritable
+}
 
+func (s *Server) Refresh() {
+	s.requestRefresh()
 }
 
-func (s *Server) serve(opts *Options) {
+func (s *Server) serve(ctx context.Context, opts *Options) {
 	http.DefaultServeMux.HandleFunc("/", s.printHtmlReport)
 	http.DefaultServeMux.HandleFunc("/detailed", s.detailed)
 	http.DefaultServeMux.HandleFunc("/refresh", s.refresh)
-	//go func() {
+	http.DefaultServeMux.HandleFunc("/refresh/status", s.refresh)
+	http.DefaultServeMux.HandleFunc("/baseline", s.baseline)
+	http.DefaultServeMux.HandleFunc("/trends", s.trends)
+	http.DefaultServeMux.HandleFunc("/run", s.run)
+	http.DefaultServeMux.HandleFunc("/test", s.test)
+	http.DefaultServeMux.HandleFunc("/job", s.job)
+	http.DefaultServeMux.HandleFunc("/compare", s.compare)
+	api.New(s).Register(http.DefaultServeMux)
+
+	server := &http.Server{Addr: opts.ListenAddr}
+	go func() {
+		<-ctx.Done()
+		// give the requests in progress a few seconds to complete
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("Unable to shut down the server: %v", err)
+		}
+	}()
 	klog.Infof("Serving reports on %s ", opts.ListenAddr)
-	if err := http.ListenAndServe(opts.ListenAddr, nil); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		klog.Exitf("Server exited: %v", err)
 	}
-	//}()
 }
 
 type Options struct {
 	LocalData               string
 	Releases                []string
+	Dashboards              []testgrid.Dashboard
 	StartDay                int
 	EndDay                  int
 	FindBugs                bool
@@ -775,8 +1719,42 @@ type Options struct {
 	Output                  string
 	FailureClusterThreshold int
 	FetchData               string
+	FetchOptions            testgrid.FetchOptions
+	KeepSnapshots           int
+	Database                string
+	IngestData              string
+	Snapshot                string
+	FetchInterval           time.Duration
+	JobCacheSize            int
+	ReportCacheSize         int
+	FetchInitialDelay       time.Duration
+	TestGridURL             string
+	TrendBucketDays         int
+	CompareStartDay         int
+	CompareEndDay           int
+	HistoricalData          string
+	Baseline                string
+	CompareReleases         bool
 	ListenAddr              string
 	Server                  bool
+	ConfigFile              string
+	ProwURL                 string
+	IgnoreTests             []string
+	Variants                []util.VariantRule
+	Owners                  []util.OwnerRule
+	KnownIssuesFile         string
+	KnownIssuesWritable     bool
+	// KnownIssues is read from KnownIssuesFile when sippy starts.  The options the configuration is reloaded into
+	// share it, so that changes made through the api are kept.
+	KnownIssues *knownissues.Registry
+	// Rules are compiled from IgnoreTests, Variants and Owners.  The analysis uses them rather than the current rules,
+	// so reports can be analyzed with new rules while the reports analyzed with the old ones are being served.
+	Rules *util.Rules
+	// BugStatuses are the statuses of the bugs of the known issues keyed by bug id, see findBugStatuses.  The bugs of
+	// known issues added since they were looked up have no status.
+	BugStatuses map[string]string
+	// changed reports whether a flag was passed on the command line, those override the configuration file.
+	changed func(name string) bool
 }
 
 func main() {
@@ -787,8 +1765,28 @@ func main() {
 		Output:                  "json",
 		FailureClusterThreshold: 10,
 		StartDay:                0,
+		CompareStartDay:         -1,
+		CompareEndDay:           -1,
+		TrendBucketDays:         1,
 		ListenAddr:              ":8080",
-		Releases:                []string{"4.4"},
+		TestGridURL:             testgrid.DefaultURL,
+		ProwURL:                 "https://prow.svc.ci.openshift.org/view/gcs",
+		IgnoreTests:             util.DefaultIgnoreTests,
+		Variants:                util.DefaultVariantRules,
+		HistoricalData:          "historical-data",
+		FetchInitialDelay:       10 * time.Minute,
+		JobCacheSize:            1000,
+		ReportCacheSize:         20,
+		KeepSnapshots:           5,
+		FetchOptions: testgrid.FetchOptions{
+			Concurrency:       10,
+			RequestsPerSecond: 5,
+			Retries:           3,
+			Backoff:           2 * time.Second,
+			Timeout:           60 * time.Second,
+		},
+		Releases:   []string{"4.4"},
+		Dashboards: testgrid.DefaultDashboards,
 	}
 
 	klog.InitFlags(nil)
@@ -796,24 +1794,50 @@ func main() {
 
 	cmd := &cobra.Command{
 		Run: func(cmd *cobra.Command, arguments []string) {
+			opt.changed = cmd.Flags().Changed
 			if err := opt.Run(); err != nil {
 				klog.Exitf("error: %v", err)
 			}
 		},
 	}
 	flags := cmd.Flags()
+	flags.StringVar(&opt.ConfigFile, "config", opt.ConfigFile, "Path to a YAML configuration file, flags override the settings in it.  In --server mode it is reloaded on every refresh")
 	flags.StringVar(&opt.LocalData, "local-data", opt.LocalData, "Path to testgrid data from local disk")
 	flags.StringArrayVar(&opt.Releases, "release", opt.Releases, "Which releases to analyze (one per arg instance)")
+	flags.Var(&dashboardsFlag{dashboards: &opt.Dashboards}, "dashboard", "Testgrid dashboard to analyze as [RELEASE,...:]TYPE=NAME, a %s in the name is replaced with the release (one per arg instance, replaces the default blocking and informing dashboards)")
 	flags.IntVar(&opt.StartDay, "start-day", opt.StartDay, "Analyze data starting from this day")
 	flags.IntVar(&opt.EndDay, "end-day", opt.EndDay, "Look at job runs going back to this day")
+	flags.IntVar(&opt.CompareStartDay, "compare-start-day", opt.CompareStartDay, "Compare to data starting from this day, defaults to the end of the analyzed period")
+	flags.IntVar(&opt.CompareEndDay, "compare-end-day", opt.CompareEndDay, "Compare to job runs going back to this day, defaults to a period the same length as the analyzed one")
+	flags.IntVar(&opt.TrendBucketDays, "trend-bucket-days", opt.TrendBucketDays, "Number of days in each point of the pass rate trends")
 	flags.Float64Var(&opt.TestSuccessThreshold, "test-success-threshold", opt.TestSuccessThreshold, "Filter results for tests that are more than this percent successful")
 	flags.BoolVar(&opt.FindBugs, "find-bugs", opt.FindBugs, "Attempt to find a bug that matches a failing test")
 	flags.StringVar(&opt.JobFilter, "job-filter", opt.JobFilter, "Only analyze jobs that match this regex")
 	flags.StringVar(&opt.FetchData, "fetch-data", opt.FetchData, "Download testgrid data to directory specified for future use with --local-data")
+	flags.IntVar(&opt.FetchOptions.Concurrency, "fetch-concurrency", opt.FetchOptions.Concurrency, "Number of concurrent downloads when fetching data")
+	flags.Float64Var(&opt.FetchOptions.RequestsPerSecond, "fetch-rate", opt.FetchOptions.RequestsPerSecond, "Maximum requests per second against testgrid when fetching data, 0 for no limit")
+	flags.IntVar(&opt.FetchOptions.Retries, "fetch-retries", opt.FetchOptions.Retries, "Number of times to retry a failed download")
+	flags.DurationVar(&opt.FetchOptions.Backoff, "fetch-backoff", opt.FetchOptions.Backoff, "Delay before the first retry of a failed download, doubled on each retry")
+	flags.DurationVar(&opt.FetchOptions.Timeout, "fetch-timeout", opt.FetchOptions.Timeout, "Timeout for each download")
+	flags.IntVar(&opt.KeepSnapshots, "keep-snapshots", opt.KeepSnapshots, "Number of complete snapshots to keep in the --fetch-data directory")
+	flags.DurationVar(&opt.FetchInterval, "fetch-interval", opt.FetchInterval, "In --server mode, fetch new data into the --local-data directory and refresh the reports at this interval, 0 to disable")
+	flags.DurationVar(&opt.FetchInitialDelay, "fetch-initial-delay", opt.FetchInitialDelay, "In --server mode, delay before the first scheduled fetch")
+	flags.IntVar(&opt.JobCacheSize, "job-cache-size", opt.JobCacheSize, "In --server mode, the number of parsed jobs to keep in memory between refreshes")
+	flags.IntVar(&opt.ReportCacheSize, "report-cache-size", opt.ReportCacheSize, "In --server mode, the number of /detailed reports to keep in memory between refreshes")
+	flags.StringVar(&opt.Snapshot, "snapshot", opt.Snapshot, "Name of the snapshot in the --local-data directory to analyze, defaults to the current snapshot")
+	flags.StringVar(&opt.Database, "database", opt.Database, "Path to a database of job run history.  Fetched data is recorded in it, and it is analyzed when --local-data is not specified")
+	flags.StringVar(&opt.IngestData, "ingest-data", opt.IngestData, "Record the testgrid data in the directory specified in the --database and exit")
+	flags.StringVar(&opt.TestGridURL, "testgrid-url", opt.TestGridURL, "Testgrid instance (or mirror) to fetch data from")
+	flags.StringVar(&opt.ProwURL, "prow-url", opt.ProwURL, "Prefix of the urls of job runs, followed by the job and the run")
+	flags.StringVar(&opt.KnownIssuesFile, "known-issues", opt.KnownIssuesFile, "Path to a YAML file of known issues, tests whose failures are tracked by a bug")
+	flags.BoolVar(&opt.KnownIssuesWritable, "known-issues-writable", opt.KnownIssuesWritable, "In --server mode, allow the known issues to be changed through the api.  The api is not authenticated")
+	flags.StringVar(&opt.HistoricalData, "historical-data", opt.HistoricalData, "Directory of named historical snapshots (such as 4.4GA) to compare releases against")
+	flags.StringVar(&opt.Baseline, "baseline", opt.Baseline, "Compare the release to this snapshot in the --historical-data directory instead of reporting on it")
+	flags.BoolVar(&opt.CompareReleases, "compare-releases", opt.CompareReleases, "Compare the releases side by side instead of reporting on them")
 	flags.IntVar(&opt.MinTestRuns, "min-test-runs", opt.MinTestRuns, "Ignore tests with less than this number of runs")
 	flags.IntVar(&opt.FailureClusterThreshold, "failure-cluster-threshold", opt.FailureClusterThreshold, "Include separate report on job runs with more than N test failures, -1 to disable")
 	flags.StringVarP(&opt.Output, "output", "o", opt.Output, "Output format for report: json, text")
-	flag.StringVar(&opt.ListenAddr, "listen", opt.ListenAddr, "The address to serve analysis reports on")
+	flags.StringVar(&opt.ListenAddr, "listen", opt.ListenAddr, "The address to serve analysis reports on")
 	flags.BoolVar(&opt.Server, "server", opt.Server, "Run in web server mode (serve reports over http)")
 
 	flags.AddGoFlag(flag.CommandLine.Lookup("v"))
@@ -824,78 +1848,369 @@ func main() {
 	}
 }
 
+// withConfig returns the options with the settings of the configuration file applied to the ones that were not
+// passed on the command line.  The options are returned as they are if there is no configuration file.
+func (o *Options) withConfig() (*Options, error) {
+	if len(o.ConfigFile) == 0 {
+		return o, nil
+	}
+	c, err := config.Load(o.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	optCopy := *o
+	set := func(name string) bool {
+		return o.changed != nil && o.changed(name)
+	}
+	if len(c.Releases) > 0 && !set("release") {
+		optCopy.Releases = c.Releases
+	}
+	if len(c.Dashboards) > 0 && !set("dashboard") {
+		optCopy.Dashboards = c.Dashboards
+	}
+	if c.Thresholds.TestSuccess != nil && !set("test-success-threshold") {
+		optCopy.TestSuccessThreshold = *c.Thresholds.TestSuccess
+	}
+	if c.Thresholds.MinTestRuns != nil && !set("min-test-runs") {
+		optCopy.MinTestRuns = *c.Thresholds.MinTestRuns
+	}
+	if c.Thresholds.FailureCluster != nil && !set("failure-cluster-threshold") {
+		optCopy.FailureClusterThreshold = *c.Thresholds.FailureCluster
+	}
+	if c.IgnoreTests != nil {
+		optCopy.IgnoreTests = c.IgnoreTests
+	}
+	if c.Variants != nil {
+		optCopy.Variants = c.Variants
+	}
+	if c.Owners != nil {
+		optCopy.Owners = c.Owners
+	}
+	if len(c.URLs.TestGrid) > 0 && !set("testgrid-url") {
+		optCopy.TestGridURL = c.URLs.TestGrid
+	}
+	if len(c.URLs.Prow) > 0 && !set("prow-url") {
+		optCopy.ProwURL = c.URLs.Prow
+	}
+	if len(c.Server.Listen) > 0 && !set("listen") {
+		optCopy.ListenAddr = c.Server.Listen
+	}
+	if len(c.LocalData) > 0 && !set("local-data") {
+		optCopy.LocalData = c.LocalData
+	}
+	if len(c.KnownIssues) > 0 && !set("known-issues") {
+		optCopy.KnownIssuesFile = c.KnownIssues
+	}
+	if c.Server.FetchInterval != nil && !set("fetch-interval") {
+		optCopy.FetchInterval = time.Duration(*c.Server.FetchInterval)
+	}
+	if c.Server.FetchInitialDelay != nil && !set("fetch-initial-delay") {
+		optCopy.FetchInitialDelay = time.Duration(*c.Server.FetchInitialDelay)
+	}
+	if c.Server.JobCacheSize != nil && !set("job-cache-size") {
+		optCopy.JobCacheSize = *c.Server.JobCacheSize
+	}
+	if c.Server.ReportCacheSize != nil && !set("report-cache-size") {
+		optCopy.ReportCacheSize = *c.Server.ReportCacheSize
+	}
+	return &optCopy, nil
+}
+
+// bugzillaTimeout bounds looking up the bugs of the known issues, so that a slow bugzilla doesn't hold up the
+// analysis.
+const bugzillaTimeout = 30 * time.Second
+
+// findBugStatuses looks up the statuses of the bugs of the known issues once, for every analysis made with the
+// options to share.  Bugs that could not be looked up have no status.
+func (o *Options) findBugStatuses() error {
+	ids := o.KnownIssues.BugIDs()
+	if len(ids) == 0 {
+		o.BugStatuses = map[string]string{}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bugzillaTimeout)
+	defer cancel()
+	statuses, err := knownissues.FindBugStatuses(ctx, ids)
+	o.BugStatuses = statuses
+	if err != nil {
+		return fmt.Errorf("unable to look up the bugs of the known issues: %v", err)
+	}
+	return nil
+}
+
+// compileRules compiles the ignored tests, variant rules and owner rules of the options, without using them yet.
+func (o *Options) compileRules() error {
+	rules, err := util.CompileRules(o.IgnoreTests, o.Variants, o.Owners)
+	if err != nil {
+		return err
+	}
+	o.Rules = rules
+	return nil
+}
+
+// dashboardsFlag collects the --dashboard flags.  The first one replaces the default dashboards.
+type dashboardsFlag struct {
+	dashboards *[]testgrid.Dashboard
+	changed    bool
+}
+
+func (f *dashboardsFlag) String() string {
+	values := []string{}
+	for _, d := range *f.dashboards {
+		values = append(values, d.String())
+	}
+	return "[" + strings.Join(values, " ") + "]"
+}
+
+func (f *dashboardsFlag) Set(value string) error {
+	dashboard, err := testgrid.ParseDashboard(value)
+	if err != nil {
+		return err
+	}
+	if !f.changed {
+		*f.dashboards = nil
+		f.changed = true
+	}
+	*f.dashboards = append(*f.dashboards, dashboard)
+	return nil
+}
+
+func (f *dashboardsFlag) Type() string {
+	return "dashboard"
+}
+
+// compareOptions are the options for the period the analysis is compared to.  Unless set, the comparison period
+// is the one of the same length immediately before the analyzed period.
+func (o *Options) compareOptions() *Options {
+	optCopy := *o
+	optCopy.StartDay = o.CompareStartDay
+	if optCopy.StartDay < 0 {
+		optCopy.StartDay = o.EndDay
+	}
+	optCopy.EndDay = o.CompareEndDay
+	if optCopy.EndDay < 0 {
+		optCopy.EndDay = optCopy.StartDay + o.EndDay - o.StartDay
+	}
+	return &optCopy
+}
+
+// dataSource reads from the selected snapshot in --local-data when it is set, otherwise from the history database
+// if there is one, otherwise testgrid is queried directly.
+func (o *Options) dataSource() (testgrid.DataSource, error) {
+	if len(o.LocalData) != 0 {
+		path, err := snapshot.Resolve(o.LocalData, o.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+		klog.V(2).Infof("Reading data from %s\n", path)
+		return testgrid.NewLocalDataSource(path, o.TestGridURL), nil
+	}
+	if len(o.Database) != 0 {
+		return history.NewDataSource(o.Database)
+	}
+	return testgrid.NewHTTPDataSource(o.TestGridURL), nil
+}
+
+// closeDataSource releases whatever the data source holds open, such as the history database.
+func closeDataSource(source testgrid.DataSource) {
+	if closer, ok := source.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			klog.Errorf("Unable to close the data source: %v", err)
+		}
+	}
+}
+
+// cachingDataSource is the data source for server mode, which keeps the data it has read in memory until the next
+// refresh.
+func (o *Options) cachingDataSource() (testgrid.DataSource, error) {
+	source, err := o.dataSource()
+	if err != nil {
+		return nil, err
+	}
+	return testgrid.NewCachingDataSource(source, o.JobCacheSize), nil
+}
+
 func (o *Options) Run() error {
+	flags := o
+	o, err := o.withConfig()
+	if err != nil {
+		return err
+	}
+	if err := o.compileRules(); err != nil {
+		return err
+	}
+	util.SetRules(o.Rules)
+	if o.KnownIssues, err = knownissues.Load(o.KnownIssuesFile); err != nil {
+		return err
+	}
+	flags.KnownIssues = o.KnownIssues
+
 	switch o.Output {
 	case "json", "text", "dashboard":
 	default:
 		return fmt.Errorf("invalid output type: %s\n", o.Output)
 	}
+	if _, err := regexp.Compile(o.JobFilter); err != nil {
+		return fmt.Errorf("invalid --job-filter: %v", err)
+	}
+	if o.KeepSnapshots < 1 {
+		return fmt.Errorf("invalid --keep-snapshots %d, at least the newest snapshot must be kept", o.KeepSnapshots)
+	}
+
+	if len(o.IngestData) != 0 {
+		if len(o.Database) == 0 {
+			return fmt.Errorf("--ingest-data requires --database")
+		}
+		path, err := snapshot.Resolve(o.IngestData, o.Snapshot)
+		if err != nil {
+			return err
+		}
+		return ingestData(testgrid.NewLocalDataSource(path, o.TestGridURL), releaseDashboards(o.Dashboards, o.Releases), o.JobFilter, o.Database)
+	}
 
 	if len(o.FetchData) != 0 {
-		downloadData(o.Releases, o.JobFilter, o.FetchData)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			klog.Infof("Cancelling fetch")
+			cancel()
+		}()
+		summary, err := downloadData(ctx, o.Releases, releaseDashboards(o.Dashboards, o.Releases), o.JobFilter, o.TestGridURL, o.FetchOptions, o.FetchData, o.KeepSnapshots, o.Database)
+		if err != nil {
+			return err
+		}
+		if len(summary.Failures) > 0 {
+			return fmt.Errorf("failed to fetch %d of %d dashboards and jobs", len(summary.Failures), summary.Dashboards+summary.Jobs)
+		}
 		return nil
 	}
-	if !o.Server {
-		analyzer := Analyzer{
-			Options: o,
-			RawData: RawData{
-				ByAll:         make(map[string]util.AggregateTestResult),
-				ByJob:         make(map[string]util.AggregateTestResult),
-				ByPlatform:    make(map[string]util.AggregateTestResult),
-				BySig:         make(map[string]util.AggregateTestResult),
-				FailureGroups: make(map[string]util.JobRunResult),
-			},
-		}
-
-		analyzer.loadData(o.Releases, o.LocalData)
+	if !o.Server && len(o.Baseline) != 0 {
+		if len(o.Releases) != 1 {
+			return fmt.Errorf("--baseline requires a single --release")
+		}
+		source, err := o.dataSource()
+		if err != nil {
+			return err
+		}
+		defer closeDataSource(source)
+		analyzer := newAnalyzer(o.Releases[0], o)
+		analyzer.loadData(o.Releases, source)
 		analyzer.analyze()
-		analyzer.printReport()
-	}
+		analyzer.prepareTestReport(true)
 
-	if o.Server {
-		server := Server{
-			analyzers: make(map[string]Analyzer),
-			options:   o,
+		baseline, err := newBaselineAnalyzer(o.Baseline, o)
+		if err != nil {
+			return err
+		}
+		printBaselineComparison(analyzer.compareToBaseline(baseline, o.Baseline), o.Output)
+		return nil
+	}
+	if !o.Server && o.CompareReleases {
+		if len(o.Releases) < 2 {
+			return fmt.Errorf("--compare-releases requires at least two --release")
 		}
+		source, err := o.dataSource()
+		if err != nil {
+			return err
+		}
+		defer closeDataSource(source)
+		analyzers := []Analyzer{}
 		for _, release := range o.Releases {
-			// most recent 7 day period (days 0-7)
-			analyzer := Analyzer{
-				Release: release,
-				Options: o,
-				RawData: RawData{
-					ByAll:         make(map[string]util.AggregateTestResult),
-					ByJob:         make(map[string]util.AggregateTestResult),
-					ByPlatform:    make(map[string]util.AggregateTestResult),
-					BySig:         make(map[string]util.AggregateTestResult),
-					FailureGroups: make(map[string]util.JobRunResult),
-				},
-			}
-			analyzer.loadData([]string{release}, o.LocalData)
-			analyzer.analyze()
-			analyzer.prepareTestReport(false)
-			server.analyzers[release] = analyzer
-
-			// prior 7 day period (days 7-14)
-			optCopy := *o
-			optCopy.EndDay = 14
-			optCopy.StartDay = 7
-			analyzer = Analyzer{
-				Release: release,
-				Options: &optCopy,
-				RawData: RawData{
-					ByAll:         make(map[string]util.AggregateTestResult),
-					ByJob:         make(map[string]util.AggregateTestResult),
-					ByPlatform:    make(map[string]util.AggregateTestResult),
-					BySig:         make(map[string]util.AggregateTestResult),
-					FailureGroups: make(map[string]util.JobRunResult),
-				},
-			}
-			analyzer.loadData([]string{release}, o.LocalData)
+			analyzer := newAnalyzer(release, o)
+			analyzer.loadData([]string{release}, source)
 			analyzer.analyze()
 			analyzer.prepareTestReport(true)
-			server.analyzers[release+"-prev"] = analyzer
+			analyzers = append(analyzers, analyzer)
 		}
-		server.serve(o)
+		printReleaseComparison(compareReleases(analyzers, o.MinTestRuns), o.Output)
+		return nil
+	}
+	if !o.Server {
+		source, err := o.dataSource()
+		if err != nil {
+			return err
+		}
+		defer closeDataSource(source)
+		if err := o.findBugStatuses(); err != nil {
+			klog.Errorf("Error loading the known issues, their bugs have no status: %v", err)
+		}
+		// each release is analyzed separately so that its results are not merged with those of the other releases
+		analyzers := []Analyzer{}
+		for _, release := range o.Releases {
+			analyzer := newAnalyzer(release, o)
+			analyzer.loadData([]string{release}, source)
+			analyzer.analyze()
+			if o.CompareStartDay >= 0 || o.CompareEndDay >= 0 {
+				prevAnalyzer := newAnalyzer(release, o.compareOptions())
+				prevAnalyzer.loadData([]string{release}, source)
+				prevAnalyzer.analyze()
+				prevAnalyzer.prepareTestReport(true)
+				analyzer.PrevReport = &prevAnalyzer.Report
+			}
+			analyzers = append(analyzers, analyzer)
+		}
+		printReports(analyzers, o.Output)
+	}
+
+	if o.Server {
+		if o.FetchInterval > 0 {
+			if len(o.LocalData) == 0 {
+				return fmt.Errorf("--fetch-interval requires --local-data")
+			}
+			if len(o.Snapshot) != 0 {
+				return fmt.Errorf("--fetch-interval cannot be used with --snapshot")
+			}
+		}
+		source, err := o.cachingDataSource()
+		if err != nil {
+			return err
+		}
+		if err := o.findBugStatuses(); err != nil {
+			klog.Errorf("Error loading the known issues, their bugs have no status: %v", err)
+		}
+		analyzers, errs := buildAnalyzers(o, source)
+		for release, err := range errs {
+			klog.Errorf("Error loading release %s: %v", release, err)
+		}
+		server := &Server{
+			analyzers: analyzers,
+			baselines: loadBaselines(o),
+			options:   o,
+			flags:     flags,
+			source:    source,
+			reports:   cache.NewLRU(o.ReportCacheSize),
+		}
+		refreshed := time.Now()
+		server.updateReleaseStatus(func(release string, status *ReleaseStatus) {
+			if err, ok := errs[release]; ok {
+				status.RefreshError = err.Error()
+				return
+			}
+			status.LastRefreshed = refreshed
+			status.LastSuccess = refreshed
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			klog.Infof("Shutting down")
+			cancel()
+		}()
+		fetched := make(chan struct{})
+		go func() {
+			defer close(fetched)
+			if o.FetchInterval > 0 {
+				server.fetchLoop(ctx)
+			}
+		}()
+		server.serve(ctx, o)
+		// wait for a fetch in progress to be cancelled
+		<-fetched
 	}
 
 	return nil
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bparees/sippy/pkg/testgrid"
+	"github.com/bparees/sippy/pkg/util"
+)
+
+func TestProcessTest(t *testing.T) {
+	endTime := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	// six runs of the job an hour apart, newest first.
+	job := testgrid.JobDetails{
+		Name:          "release-openshift-ocp-installer-e2e-aws-4.5",
+		Query:         "origin-ci-test/logs/release-openshift-ocp-installer-e2e-aws-4.5",
+		DashboardType: "blocking",
+	}
+	for i := 0; i < 6; i++ {
+		job.Timestamps = append(job.Timestamps, int(endTime.Add(-time.Duration(i+1)*time.Hour).Unix()*1000))
+		job.ChangeLists = append(job.ChangeLists, string(rune('a'+i)))
+	}
+	variant := util.Variant{"cloud": "aws"}
+
+	tests := []struct {
+		name     string
+		statuses []testgrid.TestResult
+		startCol int
+		endCol   int
+		counts   util.TestCounts
+		// failedRuns are the runs recorded as failed, out of runs.
+		runs       int
+		failedRuns int
+	}{
+		{
+			name:     "all passing",
+			statuses: []testgrid.TestResult{{Count: 6, Value: testgrid.Pass}},
+			endCol:   6,
+			counts:   util.TestCounts{Successes: 6},
+			runs:     6,
+		},
+		{
+			name:       "newest run failing",
+			statuses:   []testgrid.TestResult{{Count: 2, Value: testgrid.Fail}, {Count: 4, Value: testgrid.Pass}},
+			endCol:     6,
+			counts:     util.TestCounts{Successes: 4, Failures: 2},
+			runs:       6,
+			failedRuns: 2,
+		},
+		{
+			name:       "timeouts fail",
+			statuses:   []testgrid.TestResult{{Count: 1, Value: testgrid.TimedOut}, {Count: 5, Value: testgrid.PassWithSkips}},
+			endCol:     6,
+			counts:     util.TestCounts{Successes: 5, Failures: 1, Timeouts: 1},
+			runs:       6,
+			failedRuns: 1,
+		},
+		{
+			name:       "failure that passed on the next run",
+			statuses:   []testgrid.TestResult{{Count: 2, Value: testgrid.Pass}, {Count: 1, Value: testgrid.Fail}, {Count: 3, Value: testgrid.Pass}},
+			endCol:     6,
+			counts:     util.TestCounts{Successes: 5, Failures: 1, Flips: 1},
+			runs:       6,
+			failedRuns: 1,
+		},
+		{
+			name:       "flakes pass",
+			statuses:   []testgrid.TestResult{{Count: 1, Value: testgrid.Flaky}, {Count: 1, Value: testgrid.TimedOut}, {Count: 4, Value: testgrid.Pass}},
+			endCol:     6,
+			counts:     util.TestCounts{Successes: 5, Failures: 1, Flakes: 1, Timeouts: 1, Flips: 1},
+			runs:       6,
+			failedRuns: 1,
+		},
+		{
+			name:       "runs without a result are not counted",
+			statuses:   []testgrid.TestResult{{Count: 2, Value: testgrid.NoResult}, {Count: 1, Value: testgrid.Running}, {Count: 3, Value: testgrid.Fail}},
+			endCol:     6,
+			counts:     util.TestCounts{Failures: 3},
+			runs:       3,
+			failedRuns: 3,
+		},
+		{
+			name:     "result not counted between a failure and a pass",
+			statuses: []testgrid.TestResult{{Count: 1, Value: testgrid.Pass}, {Count: 1, Value: testgrid.Cancel}, {Count: 1, Value: testgrid.Fail}, {Count: 3, Value: testgrid.Pass}},
+			endCol:   6,
+			// the cancelled run is skipped, so the failure is still a flip as the next counted run passed.
+			counts:     util.TestCounts{Successes: 4, Failures: 1, Flips: 1},
+			runs:       5,
+			failedRuns: 1,
+		},
+		{
+			name:       "window starting inside a run of results",
+			statuses:   []testgrid.TestResult{{Count: 3, Value: testgrid.Fail}, {Count: 3, Value: testgrid.Pass}},
+			startCol:   2,
+			endCol:     4,
+			counts:     util.TestCounts{Successes: 1, Failures: 1},
+			runs:       2,
+			failedRuns: 1,
+		},
+		{
+			name:     "window after the results",
+			statuses: []testgrid.TestResult{{Count: 2, Value: testgrid.Fail}},
+			startCol: 3,
+			endCol:   6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAnalyzer("4.5", &Options{EndDay: 7, TrendBucketDays: 1, ProwURL: "https://prow.svc.ci.openshift.org/view/gcs"})
+			test := testgrid.Test{Name: "Overall", Statuses: tt.statuses}
+			meta := util.TestMeta{Name: test.Name, Jobs: map[string]interface{}{job.Name: struct{}{}}}
+			a.processTest(job, variant, test, meta, tt.startCol, tt.endCol, endTime)
+
+			byCategory := map[string]util.AggregateTestResult{
+				"all":     a.RawData.ByAll["all"],
+				"job":     a.RawData.ByJob[job.Name],
+				"variant": a.RawData.ByVariant["cloud"]["aws"],
+			}
+			for category, result := range byCategory {
+				r := result.TestResults[test.Name]
+				counts := util.TestCounts{Successes: r.Successes, Failures: r.Failures, Flakes: r.Flakes, Timeouts: r.Timeouts, Flips: r.Flips}
+				if counts != tt.counts {
+					t.Errorf("expected counts %+v by %s, got %+v", tt.counts, category, counts)
+				}
+			}
+
+			failedRuns := 0
+			for _, run := range a.RawData.FailureGroups {
+				if run.Failed {
+					failedRuns++
+				}
+				if run.Failed == run.Succeeded {
+					t.Errorf("expected run %s to either fail or succeed", run.Url)
+				}
+				if run.Variant["cloud"] != "aws" {
+					t.Errorf("expected run %s to have the variant of the job, got %v", run.Url, run.Variant)
+				}
+			}
+			if len(a.RawData.FailureGroups) != tt.runs || failedRuns != tt.failedRuns {
+				t.Errorf("expected %d runs with %d failed, got %d with %d failed", tt.runs, tt.failedRuns, len(a.RawData.FailureGroups), failedRuns)
+			}
+		})
+	}
+}
+
+func TestLoadData(t *testing.T) {
+	endTime := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	// job has runs a day apart, newest first, with the test failing in the newest run.
+	job := func(name string, days int) testgrid.JobDetails {
+		details := testgrid.JobDetails{Name: name, Query: "origin-ci-test/logs/" + name}
+		statuses := []testgrid.TestResult{{Count: 1, Value: testgrid.Fail}, {Count: days - 1, Value: testgrid.Pass}}
+		details.Tests = []testgrid.Test{
+			{Name: "Overall", Statuses: statuses},
+			{Name: "[sig-network] services should serve", Statuses: statuses},
+		}
+		for i := 0; i < days; i++ {
+			details.Timestamps = append(details.Timestamps, int(endTime.Add(-time.Duration(i*24+1)*time.Hour).Unix()*1000))
+			details.ChangeLists = append(details.ChangeLists, fmt.Sprintf("%d", i))
+		}
+		return details
+	}
+	source := testgrid.NewMemoryDataSource()
+	source.Timestamp = endTime.Add(-time.Hour)
+	source.AddJob("redhat-openshift-ocp-release-4.5-blocking", testgrid.JobSummary{OverallStatus: "FAILING"}, job("release-openshift-ocp-installer-e2e-aws-4.5", 10))
+	source.AddJob("redhat-openshift-ocp-release-4.5-blocking", testgrid.JobSummary{OverallStatus: "FLAKY"}, job("release-openshift-ocp-installer-e2e-gcp-4.5", 3))
+	source.AddJob("redhat-openshift-ocp-release-4.6-blocking", testgrid.JobSummary{OverallStatus: "FAILING"}, job("release-openshift-ocp-installer-e2e-aws-4.6", 10))
+
+	tests := []struct {
+		name      string
+		jobFilter string
+		// runs are the runs in the window of each job that was loaded.
+		runs map[string]int
+	}{
+		{
+			name: "jobs of the release",
+			runs: map[string]int{"release-openshift-ocp-installer-e2e-aws-4.5": 7, "release-openshift-ocp-installer-e2e-gcp-4.5": 3},
+		},
+		{
+			name:      "job filter",
+			jobFilter: "-gcp-",
+			runs:      map[string]int{"release-openshift-ocp-installer-e2e-gcp-4.5": 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAnalyzer("4.5", &Options{
+				Dashboards: []testgrid.Dashboard{
+					{Name: "redhat-openshift-ocp-release-%s-blocking", Type: "blocking"},
+					// dashboards with no data are left out
+					{Name: "redhat-openshift-ocp-release-%s-informing", Type: "informing"},
+				},
+				JobFilter:       tt.jobFilter,
+				EndDay:          7,
+				TrendBucketDays: 1,
+				ProwURL:         "https://prow.svc.ci.openshift.org/view/gcs",
+			})
+			a.EndTime = endTime
+			a.loadData([]string{"4.5"}, source)
+			a.analyze()
+
+			if !a.LastUpdateTime.Equal(source.Timestamp) {
+				t.Errorf("expected the data to be from %s, got %s", source.Timestamp, a.LastUpdateTime)
+			}
+			if len(a.RawData.JobDetails) != len(tt.runs) {
+				t.Errorf("expected %d jobs, got %d", len(tt.runs), len(a.RawData.JobDetails))
+			}
+			for _, details := range a.RawData.JobDetails {
+				if details.DashboardType != "blocking" {
+					t.Errorf("expected job %s to be from the blocking dashboard, got %q", details.Name, details.DashboardType)
+				}
+			}
+			for name, runs := range tt.runs {
+				r := a.RawData.ByJob[name].TestResults["[sig-network] services should serve"]
+				if r.Successes+r.Failures != runs || r.Failures != 1 {
+					t.Errorf("expected %d runs of the test with 1 failure in job %s, got %+v", runs, name, r)
+				}
+			}
+			total := 0
+			for _, runs := range tt.runs {
+				total += runs
+			}
+			if r := a.RawData.ByAll["all"].TestResults["[sig-network] services should serve"]; r.Successes+r.Failures != total {
+				t.Errorf("expected %d runs of the test across the jobs, got %+v", total, r)
+			}
+			if len(a.RawData.FailureGroups) != total {
+				t.Errorf("expected %d job runs, got %d", total, len(a.RawData.FailureGroups))
+			}
+		})
+	}
+}
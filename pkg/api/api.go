@@ -0,0 +1,651 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/bparees/sippy/pkg/knownissues"
+	"github.com/bparees/sippy/pkg/util"
+)
+
+const (
+	// Prefix is the path every api endpoint is served under.
+	Prefix = "/api/v1"
+
+	defaultLimit = 100
+)
+
+// Reports provides the reports served by the api.
+type Reports interface {
+	// Releases returns the releases that have a report.
+	Releases() []string
+	// Report returns the current report for the release.
+	Report(release string) (util.TestReport, bool)
+	// JobRun returns the job run with the prow url.
+	JobRun(url string) (util.JobRunSummary, bool)
+	// Test returns the breakdown of the results of the test in the release.
+	Test(release, name string) (util.TestSummary, bool)
+	// Job returns the run history of the job in the release.
+	Job(release, name string) (util.JobSummary, bool)
+	// CompareReleases puts the reports of the releases side by side.
+	CompareReleases(releases []string) util.ReleaseComparison
+	// KnownIssues returns the registry of known issues.
+	KnownIssues() *knownissues.Registry
+	// KnownIssuesWritable reports whether the known issues may be changed through the api.
+	KnownIssuesWritable() bool
+	// Refresh rebuilds the reports in the background, e.g. after the known issues changed.
+	Refresh()
+}
+
+type Server struct {
+	reports Reports
+}
+
+func New(reports Reports) *Server {
+	return &Server{reports: reports}
+}
+
+// Register adds the api endpoints to the mux.
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc(Prefix+"/releases", s.releases)
+	mux.HandleFunc(Prefix+"/report", s.report)
+	mux.HandleFunc(Prefix+"/tests", s.tests)
+	mux.HandleFunc(Prefix+"/jobs", s.jobs)
+	mux.HandleFunc(Prefix+"/variants", s.variants)
+	mux.HandleFunc(Prefix+"/platforms", s.platforms)
+	mux.HandleFunc(Prefix+"/sigs", s.sigs)
+	mux.HandleFunc(Prefix+"/components", s.components)
+	mux.HandleFunc(Prefix+"/teams", s.teams)
+	mux.HandleFunc(Prefix+"/run", s.run)
+	mux.HandleFunc(Prefix+"/test", s.test)
+	mux.HandleFunc(Prefix+"/job", s.job)
+	mux.HandleFunc(Prefix+"/compare", s.compare)
+	mux.HandleFunc(Prefix+"/knownissues", s.knownIssues)
+}
+
+// Error is the body of every unsuccessful response.  Param is the query parameter that was invalid, if any.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+}
+
+// ParamError is returned when a query parameter is invalid.
+type ParamError struct {
+	Param  string
+	Value  string
+	Reason string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid %s %q, %s", e.Param, e.Value, e.Reason)
+}
+
+// Page is one page of a sorted and filtered list.  Total is the number of items that matched the filter.
+type Page struct {
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Items  interface{} `json:"items"`
+}
+
+type Release struct {
+	Release   string    `json:"release"`
+	Timestamp time.Time `json:"timestamp"`
+	StartDay  int       `json:"startDay"`
+	EndDay    int       `json:"endDay"`
+}
+
+// Aggregate summarizes the test results of a group of tests, such as a sig.  The failing tests themselves are
+// listed by the tests endpoint.
+type Aggregate struct {
+	Name               string  `json:"name"`
+	Successes          int     `json:"successes"`
+	Failures           int     `json:"failures"`
+	Flakes             int     `json:"flakes"`
+	Timeouts           int     `json:"timeouts"`
+	TestPassPercentage float64 `json:"testPassPercentage"`
+	FailingTests       int     `json:"failingTests"`
+}
+
+// WriteJSON writes v as the json body of the response.
+func WriteJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Unable to write response: %v", err)
+	}
+}
+
+// WriteError writes an Error as the json body of the response.
+func WriteError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	WriteJSON(w, code, Error{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// WriteBadRequest writes err as a bad request, naming the invalid parameter if err is a ParamError.
+func WriteBadRequest(w http.ResponseWriter, err error) {
+	body := Error{Code: http.StatusBadRequest, Message: err.Error()}
+	if paramErr, ok := err.(*ParamError); ok {
+		body.Param = paramErr.Param
+	}
+	WriteJSON(w, http.StatusBadRequest, body)
+}
+
+// listOptions are the filtering, sorting and pagination query parameters shared by every list endpoint.
+type listOptions struct {
+	filter *regexp.Regexp
+	sort   string
+	desc   bool
+	offset int
+	limit  int
+}
+
+func parseListOptions(req *http.Request, defaultSort string, sorts []string) (listOptions, error) {
+	query := req.URL.Query()
+	opts := listOptions{sort: defaultSort, limit: defaultLimit}
+
+	if t := query.Get("filter"); len(t) != 0 {
+		filter, err := regexp.Compile(t)
+		if err != nil {
+			return opts, &ParamError{Param: "filter", Value: t, Reason: err.Error()}
+		}
+		opts.filter = filter
+	}
+
+	if t := query.Get("sort"); len(t) != 0 {
+		valid := false
+		for _, s := range sorts {
+			valid = valid || s == t
+		}
+		if !valid {
+			return opts, &ParamError{Param: "sort", Value: t, Reason: fmt.Sprintf("must be one of %v", sorts)}
+		}
+		opts.sort = t
+	}
+
+	switch t := query.Get("order"); t {
+	case "", "asc":
+	case "desc":
+		opts.desc = true
+	default:
+		return opts, &ParamError{Param: "order", Value: t, Reason: "must be asc or desc"}
+	}
+
+	var err error
+	if opts.offset, err = intParam(req, "offset", 0); err != nil {
+		return opts, err
+	}
+	if opts.limit, err = intParam(req, "limit", defaultLimit); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+func intParam(req *http.Request, name string, value int) (int, error) {
+	return IntParam(req, name, value, 0, math.MaxInt32)
+}
+
+// IntParam parses the named query parameter as an integer between min and max, inclusive.  value is returned if
+// the parameter is not set.
+func IntParam(req *http.Request, name string, value, min, max int) (int, error) {
+	t := req.URL.Query().Get(name)
+	if len(t) == 0 {
+		return value, nil
+	}
+	i, err := strconv.Atoi(t)
+	if err != nil || i < min || i > max {
+		reason := fmt.Sprintf("must be an integer between %d and %d", min, max)
+		if max == math.MaxInt32 {
+			reason = fmt.Sprintf("must be an integer of at least %d", min)
+			if min == 0 {
+				reason = "must be a non-negative integer"
+			}
+		}
+		return 0, &ParamError{Param: name, Value: t, Reason: reason}
+	}
+	return i, nil
+}
+
+// FloatParam parses the named query parameter as a number between min and max, inclusive.  value is returned if
+// the parameter is not set.
+func FloatParam(req *http.Request, name string, value, min, max float64) (float64, error) {
+	t := req.URL.Query().Get(name)
+	if len(t) == 0 {
+		return value, nil
+	}
+	f, err := strconv.ParseFloat(t, 64)
+	if err != nil || math.IsNaN(f) || f < min || f > max {
+		return 0, &ParamError{Param: name, Value: t, Reason: fmt.Sprintf("must be a number between %g and %g", min, max)}
+	}
+	return f, nil
+}
+
+func (o listOptions) matches(name string) bool {
+	return o.filter == nil || o.filter.MatchString(name)
+}
+
+// less applies the sort order to the result of an ascending comparison.
+func (o listOptions) less(lt, eq bool) bool {
+	if eq {
+		return false
+	}
+	return lt != o.desc
+}
+
+// page returns the bounds of the requested page of a list of length total.  A limit of 0 returns everything
+// after the offset.
+func (o listOptions) page(total int) (int, int) {
+	start := o.offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if o.limit > 0 && start+o.limit < end {
+		end = start + o.limit
+	}
+	return start, end
+}
+
+// release looks up the report for the release parameter, writing an error response if there is none.
+func (s *Server) release(w http.ResponseWriter, req *http.Request) (util.TestReport, bool) {
+	release := req.URL.Query().Get("release")
+	if len(release) == 0 {
+		WriteError(w, http.StatusBadRequest, "the release parameter is required, must be one of %v", s.reports.Releases())
+		return util.TestReport{}, false
+	}
+	report, ok := s.reports.Report(release)
+	if !ok {
+		WriteError(w, http.StatusNotFound, "invalid release %q, must be one of %v", release, s.reports.Releases())
+		return util.TestReport{}, false
+	}
+	return report, true
+}
+
+func (s *Server) releases(w http.ResponseWriter, req *http.Request) {
+	releases := []Release{}
+	for _, release := range s.reports.Releases() {
+		report, ok := s.reports.Report(release)
+		if !ok {
+			continue
+		}
+		releases = append(releases, Release{
+			Release:   release,
+			Timestamp: report.Timestamp,
+			StartDay:  report.StartDay,
+			EndDay:    report.EndDay,
+		})
+	}
+	WriteJSON(w, http.StatusOK, releases)
+}
+
+func (s *Server) report(w http.ResponseWriter, req *http.Request) {
+	report, ok := s.release(w, req)
+	if !ok {
+		return
+	}
+	WriteJSON(w, http.StatusOK, report)
+}
+
+// tests lists the failing tests in the report.  By default these are the tests across all jobs, the sig, component,
+// team, variant or job parameters select the failing tests of a single sig, component, team, variant value or job
+// instead.  A variant is written as DIMENSION=VALUE, e.g. cloud=aws.
+func (s *Server) tests(w http.ResponseWriter, req *http.Request) {
+	report, ok := s.release(w, req)
+	if !ok {
+		return
+	}
+	opts, err := parseListOptions(req, "passPercentage", []string{"name", "passPercentage", "flakePercentage", "runs", "failures", "flakes"})
+	if err != nil {
+		WriteBadRequest(w, err)
+		return
+	}
+	minRuns, err := intParam(req, "minRuns", 0)
+	if err != nil {
+		WriteBadRequest(w, err)
+		return
+	}
+
+	query := req.URL.Query()
+	group, key := report.All, "all"
+	if t := query.Get("variant"); len(t) != 0 {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			WriteBadRequest(w, &ParamError{Param: "variant", Value: t, Reason: "must be DIMENSION=VALUE, e.g. cloud=aws"})
+			return
+		}
+		group, key = report.ByVariant[parts[0]], parts[1]
+	}
+	for _, g := range []struct {
+		param  string
+		groups map[string]util.SortedAggregateTestResult
+	}{{"sig", report.BySig}, {"component", report.ByComponent}, {"team", report.ByTeam}, {"job", report.ByJob}} {
+		if t := query.Get(g.param); len(t) != 0 {
+			group, key = g.groups, t
+		}
+	}
+	result, ok := group[key]
+	if !ok {
+		WriteError(w, http.StatusNotFound, "no test results for %q", key)
+		return
+	}
+
+	tests := []util.TestResult{}
+	for _, test := range result.TestResults {
+		if opts.matches(test.Name) && test.Successes+test.Failures >= minRuns {
+			tests = append(tests, test)
+		}
+	}
+	sort.SliceStable(tests, func(i, j int) bool {
+		a, b := tests[i], tests[j]
+		switch opts.sort {
+		case "name":
+			return opts.less(a.Name < b.Name, a.Name == b.Name)
+		case "flakePercentage":
+			return opts.less(a.FlakePercentage < b.FlakePercentage, a.FlakePercentage == b.FlakePercentage)
+		case "runs":
+			return opts.less(a.Successes+a.Failures < b.Successes+b.Failures, a.Successes+a.Failures == b.Successes+b.Failures)
+		case "failures":
+			return opts.less(a.Failures < b.Failures, a.Failures == b.Failures)
+		case "flakes":
+			return opts.less(a.Flakes < b.Flakes, a.Flakes == b.Flakes)
+		}
+		return opts.less(a.PassPercentage < b.PassPercentage, a.PassPercentage == b.PassPercentage)
+	})
+
+	start, end := opts.page(len(tests))
+	WriteJSON(w, http.StatusOK, Page{Total: len(tests), Offset: opts.offset, Limit: opts.limit, Items: tests[start:end]})
+}
+
+func (s *Server) jobs(w http.ResponseWriter, req *http.Request) {
+	report, ok := s.release(w, req)
+	if !ok {
+		return
+	}
+	s.writeJobResults(w, req, util.SummarizeJobsByName(report))
+}
+
+// variants lists the job pass rate of each value of the variant dimensions, named DIMENSION=VALUE.  The dimension
+// parameter limits them to a single dimension.
+func (s *Server) variants(w http.ResponseWriter, req *http.Request) {
+	report, ok := s.release(w, req)
+	if !ok {
+		return
+	}
+	dimensions := util.VariantDimensions()
+	if t := req.URL.Query().Get("dimension"); len(t) != 0 {
+		found := false
+		for _, d := range dimensions {
+			found = found || d == t
+		}
+		if !found {
+			WriteBadRequest(w, &ParamError{Param: "dimension", Value: t, Reason: fmt.Sprintf("must be one of %v", dimensions)})
+			return
+		}
+		dimensions = []string{t}
+	}
+	variants := []util.JobResult{}
+	for _, dimension := range dimensions {
+		for _, v := range util.SummarizeJobsByVariant(report, dimension) {
+			v.Name = util.VariantName(dimension, v.Variant)
+			variants = append(variants, v)
+		}
+	}
+	s.writeJobResults(w, req, variants)
+}
+
+// platforms lists the job pass rate of each value of the cloud dimension, named by the value.  It is kept for the
+// clients from before the variant dimensions were configurable, use variants?dimension=cloud instead.
+func (s *Server) platforms(w http.ResponseWriter, req *http.Request) {
+	report, ok := s.release(w, req)
+	if !ok {
+		return
+	}
+	platforms := util.SummarizeJobsByVariant(report, "cloud")
+	for i := range platforms {
+		platforms[i].Name = platforms[i].Variant
+		platforms[i].Platform = platforms[i].Variant
+	}
+	s.writeJobResults(w, req, platforms)
+}
+
+func (s *Server) writeJobResults(w http.ResponseWriter, req *http.Request, results []util.JobResult) {
+	opts, err := parseListOptions(req, "passPercentage", []string{"name", "passPercentage", "runs", "failures"})
+	if err != nil {
+		WriteBadRequest(w, err)
+		return
+	}
+
+	jobs := []util.JobResult{}
+	for _, job := range results {
+		if opts.matches(job.Name) {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+	sort.SliceStable(jobs, func(i, j int) bool {
+		a, b := jobs[i], jobs[j]
+		switch opts.sort {
+		case "name":
+			return opts.less(a.Name < b.Name, a.Name == b.Name)
+		case "runs":
+			return opts.less(a.Successes+a.Failures < b.Successes+b.Failures, a.Successes+a.Failures == b.Successes+b.Failures)
+		case "failures":
+			return opts.less(a.Failures < b.Failures, a.Failures == b.Failures)
+		}
+		return opts.less(a.PassPercentage < b.PassPercentage, a.PassPercentage == b.PassPercentage)
+	})
+
+	start, end := opts.page(len(jobs))
+	WriteJSON(w, http.StatusOK, Page{Total: len(jobs), Offset: opts.offset, Limit: opts.limit, Items: jobs[start:end]})
+}
+
+func (s *Server) sigs(w http.ResponseWriter, req *http.Request) {
+	if report, ok := s.release(w, req); ok {
+		s.writeAggregates(w, req, report.BySig)
+	}
+}
+
+func (s *Server) components(w http.ResponseWriter, req *http.Request) {
+	if report, ok := s.release(w, req); ok {
+		s.writeAggregates(w, req, report.ByComponent)
+	}
+}
+
+func (s *Server) teams(w http.ResponseWriter, req *http.Request) {
+	if report, ok := s.release(w, req); ok {
+		s.writeAggregates(w, req, report.ByTeam)
+	}
+}
+
+// writeAggregates writes a page of the test pass rates of a group of tests, such as the sigs.
+func (s *Server) writeAggregates(w http.ResponseWriter, req *http.Request, groups map[string]util.SortedAggregateTestResult) {
+	opts, err := parseListOptions(req, "testPassPercentage", []string{"name", "testPassPercentage", "runs", "failures"})
+	if err != nil {
+		WriteBadRequest(w, err)
+		return
+	}
+
+	aggregates := []Aggregate{}
+	for name, group := range groups {
+		if !opts.matches(name) {
+			continue
+		}
+		aggregates = append(aggregates, Aggregate{
+			Name:               name,
+			Successes:          group.Successes,
+			Failures:           group.Failures,
+			Flakes:             group.Flakes,
+			Timeouts:           group.Timeouts,
+			TestPassPercentage: group.TestPassPercentage,
+			FailingTests:       len(group.TestResults),
+		})
+	}
+	// the groups come from a map, so sort by name first for a stable order between requests
+	sort.SliceStable(aggregates, func(i, j int) bool {
+		return aggregates[i].Name < aggregates[j].Name
+	})
+	sort.SliceStable(aggregates, func(i, j int) bool {
+		a, b := aggregates[i], aggregates[j]
+		switch opts.sort {
+		case "name":
+			return opts.less(a.Name < b.Name, a.Name == b.Name)
+		case "runs":
+			return opts.less(a.Successes+a.Failures < b.Successes+b.Failures, a.Successes+a.Failures == b.Successes+b.Failures)
+		case "failures":
+			return opts.less(a.Failures < b.Failures, a.Failures == b.Failures)
+		}
+		return opts.less(a.TestPassPercentage < b.TestPassPercentage, a.TestPassPercentage == b.TestPassPercentage)
+	})
+
+	start, end := opts.page(len(aggregates))
+	WriteJSON(w, http.StatusOK, Page{Total: len(aggregates), Offset: opts.offset, Limit: opts.limit, Items: aggregates[start:end]})
+}
+
+// run reports the tests that failed in the job run with the prow url given by the url parameter.
+func (s *Server) run(w http.ResponseWriter, req *http.Request) {
+	url := req.URL.Query().Get("url")
+	if len(url) == 0 {
+		WriteBadRequest(w, &ParamError{Param: "url", Value: url, Reason: "must be the prow url of a job run"})
+		return
+	}
+	run, ok := s.reports.JobRun(url)
+	if !ok {
+		WriteError(w, http.StatusNotFound, "no job run found for %q", url)
+		return
+	}
+	WriteJSON(w, http.StatusOK, run)
+}
+
+// test breaks down the results of the test given by the name parameter by release, job and variant.
+func (s *Server) test(w http.ResponseWriter, req *http.Request) {
+	if _, ok := s.release(w, req); !ok {
+		return
+	}
+	query := req.URL.Query()
+	name := query.Get("name")
+	if len(name) == 0 {
+		WriteBadRequest(w, &ParamError{Param: "name", Value: name, Reason: "must be the name of a test"})
+		return
+	}
+	test, ok := s.reports.Test(query.Get("release"), name)
+	if !ok {
+		WriteError(w, http.StatusNotFound, "no test results for %q", name)
+		return
+	}
+	WriteJSON(w, http.StatusOK, test)
+}
+
+// job reports the run history of the job given by the name parameter.
+func (s *Server) job(w http.ResponseWriter, req *http.Request) {
+	if _, ok := s.release(w, req); !ok {
+		return
+	}
+	query := req.URL.Query()
+	name := query.Get("name")
+	if len(name) == 0 {
+		WriteBadRequest(w, &ParamError{Param: "name", Value: name, Reason: "must be the name of a job"})
+		return
+	}
+	job, ok := s.reports.Job(query.Get("release"), name)
+	if !ok {
+		WriteError(w, http.StatusNotFound, "no runs of job %q", name)
+		return
+	}
+	WriteJSON(w, http.StatusOK, job)
+}
+
+// compare puts the releases given by the release parameters side by side, all of the releases by default.
+func (s *Server) compare(w http.ResponseWriter, req *http.Request) {
+	available := s.reports.Releases()
+	releases := req.URL.Query()["release"]
+	if len(releases) == 0 {
+		releases = available
+	}
+	for _, release := range releases {
+		if _, ok := s.reports.Report(release); !ok {
+			WriteError(w, http.StatusNotFound, "invalid release %q, must be one of %v", release, available)
+			return
+		}
+	}
+	WriteJSON(w, http.StatusOK, s.reports.CompareReleases(releases))
+}
+
+// knownIssues lists the known issues on GET, adds the issue in the body on POST, and replaces or removes the issue
+// given by the id parameter on PUT and DELETE.  Changes are forbidden unless the known issues are writable, the
+// reports are refreshed after every change.
+func (s *Server) knownIssues(w http.ResponseWriter, req *http.Request) {
+	registry := s.reports.KnownIssues()
+	switch req.Method {
+	case http.MethodGet:
+		WriteJSON(w, http.StatusOK, registry.Issues())
+		return
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, "method %s is not allowed, must be one of GET, POST, PUT or DELETE", req.Method)
+		return
+	}
+	if !s.reports.KnownIssuesWritable() {
+		WriteError(w, http.StatusForbidden, "the known issues are read only, sippy must be started with --known-issues-writable to change them")
+		return
+	}
+
+	if req.Method == http.MethodPost {
+		issue := knownissues.Issue{}
+		if err := json.NewDecoder(req.Body).Decode(&issue); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid known issue: %v", err)
+			return
+		}
+		issue, err := registry.Add(issue)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "unable to add known issue: %v", err)
+			return
+		}
+		s.reports.Refresh()
+		WriteJSON(w, http.StatusCreated, issue)
+		return
+	}
+
+	value := req.URL.Query().Get("id")
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		WriteBadRequest(w, &ParamError{Param: "id", Value: value, Reason: "must be the id of a known issue"})
+		return
+	}
+	issue, ok := registry.Get(id)
+	if !ok {
+		WriteError(w, http.StatusNotFound, "no known issue with id %d", id)
+		return
+	}
+	if req.Method == http.MethodDelete {
+		if err := registry.Delete(id); err != nil {
+			WriteError(w, http.StatusBadRequest, "unable to delete known issue: %v", err)
+			return
+		}
+		s.reports.Refresh()
+		WriteJSON(w, http.StatusOK, issue)
+		return
+	}
+	issue = knownissues.Issue{}
+	if err := json.NewDecoder(req.Body).Decode(&issue); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid known issue: %v", err)
+		return
+	}
+	issue.ID = id
+	if err := registry.Update(issue); err != nil {
+		WriteError(w, http.StatusBadRequest, "unable to update known issue: %v", err)
+		return
+	}
+	s.reports.Refresh()
+	WriteJSON(w, http.StatusOK, issue)
+}
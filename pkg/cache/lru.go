@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a fixed size cache that evicts the least recently used entry once it is full.  It is safe for concurrent
+// use.
+type LRU struct {
+	size    int
+	lock    sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key   string
+	value interface{}
+}
+
+// NewLRU returns a cache holding up to size entries.  A cache with a size less than 1 holds nothing.
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value cached for key and marks it as the most recently used.
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*entry).value, true
+}
+
+// Add caches value for key, replacing any value already cached for it.
+func (c *LRU) Add(key string, value interface{}) {
+	if c.size < 1 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*entry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// Len is the number of entries in the cache.
+func (c *LRU) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
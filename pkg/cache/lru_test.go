@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	// each step adds a key, or gets it if the key starts with "get ".
+	tests := []struct {
+		name  string
+		size  int
+		steps []string
+		keys  []string
+	}{
+		{name: "below the size", size: 3, steps: []string{"a", "b"}, keys: []string{"a", "b"}},
+		{name: "evicts the oldest", size: 2, steps: []string{"a", "b", "c"}, keys: []string{"b", "c"}},
+		{name: "get marks as used", size: 2, steps: []string{"a", "b", "get a", "c"}, keys: []string{"a", "c"}},
+		{name: "add marks as used", size: 2, steps: []string{"a", "b", "a", "c"}, keys: []string{"a", "c"}},
+		{name: "get of a missing key", size: 2, steps: []string{"a", "get b", "c"}, keys: []string{"a", "c"}},
+		{name: "size zero holds nothing", size: 0, steps: []string{"a", "b"}, keys: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(tt.size)
+			for i, step := range tt.steps {
+				if len(step) > 4 && step[:4] == "get " {
+					c.Get(step[4:])
+					continue
+				}
+				c.Add(step, i)
+			}
+			keys := []string{}
+			for _, key := range []string{"a", "b", "c"} {
+				if _, ok := c.Get(key); ok {
+					keys = append(keys, key)
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("expected keys %v, got %v", tt.keys, keys)
+			}
+			if c.Len() != len(tt.keys) {
+				t.Errorf("expected %d entries, got %d", len(tt.keys), c.Len())
+			}
+		})
+	}
+}
+
+func TestLRUReplace(t *testing.T) {
+	c := NewLRU(2)
+	c.Add("a", 1)
+	c.Add("a", 2)
+	if value, ok := c.Get("a"); !ok || value != 2 {
+		t.Errorf("expected the replaced value 2, got %v", value)
+	}
+	if c.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", c.Len())
+	}
+}
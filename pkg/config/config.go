@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/bparees/sippy/pkg/testgrid"
+	"github.com/bparees/sippy/pkg/util"
+)
+
+// Config is the content of a sippy configuration file.  Settings that are left out keep their defaults, and the
+// ones passed as flags on the command line override the file.
+type Config struct {
+	Releases []string `yaml:"releases"`
+	// LocalData is the directory of testgrid data, as with --local-data.
+	LocalData  string               `yaml:"localData"`
+	Dashboards []testgrid.Dashboard `yaml:"dashboards"`
+	Thresholds Thresholds           `yaml:"thresholds"`
+	// IgnoreTests are regexes of the tests that are left out of the failing and flaky test lists.
+	IgnoreTests []string `yaml:"ignoreTests"`
+	// Variants are the rules that parse job names into the value of each variant dimension, e.g. cloud or arch.
+	Variants []util.VariantRule `yaml:"variants"`
+	// Owners are the rules that assign tests to a sig, component and team, ahead of the [sig-...] tag of the name.
+	Owners []util.OwnerRule `yaml:"owners"`
+	// KnownIssues is the file of known issues, as with --known-issues.  A new file takes effect when sippy restarts.
+	KnownIssues string `yaml:"knownIssues"`
+	URLs        URLs   `yaml:"urls"`
+	Server      Server `yaml:"server"`
+}
+
+// Thresholds match the --test-success-threshold, --min-test-runs and --failure-cluster-threshold flags.
+type Thresholds struct {
+	TestSuccess    *float64 `yaml:"testSuccess"`
+	MinTestRuns    *int     `yaml:"minTestRuns"`
+	FailureCluster *int     `yaml:"failureCluster"`
+}
+
+// URLs are the external services sippy reads from and links to.  Prow is the prefix of the job run urls.
+type URLs struct {
+	TestGrid string `yaml:"testgrid"`
+	Prow     string `yaml:"prow"`
+}
+
+// Server holds the settings of --server mode.  Listen and FetchInitialDelay only take effect when the server starts.
+// The others are reloaded with the rest of the file: a new FetchInterval is used from the next fetch (scheduled fetches
+// can be stopped but not started by a reload), JobCacheSize and ReportCacheSize from the next refresh.
+type Server struct {
+	Listen            string    `yaml:"listen"`
+	FetchInterval     *Duration `yaml:"fetchInterval"`
+	FetchInitialDelay *Duration `yaml:"fetchInitialDelay"`
+	JobCacheSize      *int      `yaml:"jobCacheSize"`
+	ReportCacheSize   *int      `yaml:"reportCacheSize"`
+}
+
+// Duration is a time.Duration written as a string such as 1h30m.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Load reads and validates the configuration file.  Unknown settings are rejected so that typos are not silently
+// ignored.
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config %s: %v", path, err)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(b, config); err != nil {
+		return nil, fmt.Errorf("unable to parse config %s: %v", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	for _, d := range c.Dashboards {
+		if _, err := testgrid.ParseDashboard(d.String()); err != nil {
+			return err
+		}
+	}
+	_, err := util.CompileRules(c.IgnoreTests, c.Variants, c.Owners)
+	return err
+}
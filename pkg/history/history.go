@@ -0,0 +1,436 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"k8s.io/klog"
+
+	"github.com/bparees/sippy/pkg/testgrid"
+)
+
+// The database is laid out as:
+//
+//	runs/<dashboard>/<job>/<changelist> -> runRecord
+//	summaries/<dashboard>/<job>         -> testgrid.JobSummary
+//	testNames/<name>                    -> test id
+//	testIDs/<test id>                   -> name
+//	meta/lastIngest                     -> time of the last ingest
+//
+// Test names are stored once and referred to by id from each run, since every run of a job repeats the same
+// (long) test names.
+var (
+	runsBucket      = []byte("runs")
+	summariesBucket = []byte("summaries")
+	testNamesBucket = []byte("testNames")
+	testIDsBucket   = []byte("testIDs")
+	metaBucket      = []byte("meta")
+
+	lastIngestKey = []byte("lastIngest")
+)
+
+// JobRun is a single run of a job, i.e. one column of the job's testgrid table.
+type JobRun struct {
+	Dashboard   string
+	Job         string
+	ChangeList  string
+	Timestamp   int
+	Query       string
+	TestGridUrl string
+	Tests       map[string]testgrid.TestStatus
+}
+
+func (r JobRun) Time() time.Time {
+	return time.Unix(0, int64(r.Timestamp)*int64(time.Millisecond))
+}
+
+type runRecord struct {
+	Timestamp   int    `json:"timestamp"`
+	Query       string `json:"query"`
+	TestGridUrl string `json:"testGridUrl"`
+	// Results is a sequence of uvarint test id and status pairs.
+	Results []byte `json:"results"`
+}
+
+// DB is an embedded database of job runs that retains history beyond testgrid's retention window.
+type DB struct {
+	bolt *bolt.DB
+}
+
+// Open opens the database for ingesting runs, creating it if needed.  Only one process may have the database
+// open for writing, and while it does readers will block.
+func Open(path string) (*DB, error) {
+	b, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 60 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open history database %s: %v", path, err)
+	}
+	err = b.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{runsBucket, summariesBucket, testNamesBucket, testIDsBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	return &DB{bolt: b}, nil
+}
+
+// OpenReadOnly opens an existing database for querying, any number of processes may do so at once.
+func OpenReadOnly(path string) (*DB, error) {
+	b, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 60 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open history database %s: %v", path, err)
+	}
+	return &DB{bolt: b}, nil
+}
+
+func (d *DB) Close() error {
+	return d.bolt.Close()
+}
+
+func testID(tx *bolt.Tx, name string) (uint64, error) {
+	names := tx.Bucket(testNamesBucket)
+	if v := names.Get([]byte(name)); v != nil {
+		return binary.BigEndian.Uint64(v), nil
+	}
+	id, err := names.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	if err := names.Put([]byte(name), key); err != nil {
+		return 0, err
+	}
+	return id, tx.Bucket(testIDsBucket).Put(key, []byte(name))
+}
+
+func testName(tx *bolt.Tx, id uint64) string {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return string(tx.Bucket(testIDsBucket).Get(key))
+}
+
+// Ingest records every run in the job details as a job run, along with the job's summary.  Runs are keyed by
+// changelist, so ingesting overlapping data is safe: a run that was already recorded is updated in place (it may
+// have still been running when it was first ingested).  It returns the number of runs that were new.
+func (d *DB) Ingest(dashboard string, summary testgrid.JobSummary, details testgrid.JobDetails) (int, error) {
+	added := 0
+	err := d.bolt.Update(func(tx *bolt.Tx) error {
+		jobBucket, err := nestedBucket(tx.Bucket(runsBucket), dashboard, details.Name)
+		if err != nil {
+			return err
+		}
+
+		// map each column to the status of every test in it
+		columns := make([][]byte, len(details.ChangeLists))
+		buf := make([]byte, binary.MaxVarintLen64)
+		for _, test := range details.Tests {
+			id, err := testID(tx, test.Name)
+			if err != nil {
+				return err
+			}
+			col := 0
+			for _, result := range test.Statuses {
+				for i := 0; i < result.Count && col < len(columns); i++ {
+					if result.Value != testgrid.NoResult {
+						n := binary.PutUvarint(buf, id)
+						columns[col] = append(columns[col], buf[:n]...)
+						n = binary.PutUvarint(buf, uint64(result.Value))
+						columns[col] = append(columns[col], buf[:n]...)
+					}
+					col++
+				}
+			}
+		}
+
+		for i, changeList := range details.ChangeLists {
+			if i >= len(details.Timestamps) {
+				break
+			}
+			if jobBucket.Get([]byte(changeList)) == nil {
+				added++
+			}
+			record, err := json.Marshal(runRecord{
+				Timestamp:   details.Timestamps[i],
+				Query:       details.Query,
+				TestGridUrl: details.TestGridUrl,
+				Results:     columns[i],
+			})
+			if err != nil {
+				return err
+			}
+			if err := jobBucket.Put([]byte(changeList), record); err != nil {
+				return err
+			}
+		}
+
+		summaries, err := nestedBucket(tx.Bucket(summariesBucket), dashboard)
+		if err != nil {
+			return err
+		}
+		s, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+		if err := summaries.Put([]byte(details.Name), s); err != nil {
+			return err
+		}
+
+		now, err := time.Now().MarshalText()
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Put(lastIngestKey, now)
+	})
+	klog.V(4).Infof("Ingested %d new runs of job %s from dashboard %s\n", added, details.Name, dashboard)
+	return added, err
+}
+
+// IngestFrom ingests every job on the dashboards from the data source that is accepted by the filter.  It returns
+// the number of new runs that were recorded.
+func (d *DB) IngestFrom(source testgrid.DataSource, dashboards []string, filter func(jobName string, job testgrid.JobSummary) bool) (int, error) {
+	added := 0
+	for _, dashboard := range dashboards {
+		jobs, _, err := source.JobSummaries(dashboard)
+		if err != nil {
+			klog.Errorf("Error loading dashboard page %s: %v\n", dashboard, err)
+			continue
+		}
+		for jobName, job := range jobs {
+			if filter != nil && !filter(jobName, job) {
+				continue
+			}
+			details, err := source.JobDetails(dashboard, jobName)
+			if err != nil {
+				klog.Errorf("Error loading job details for %s: %v\n", jobName, err)
+				continue
+			}
+			n, err := d.Ingest(dashboard, job, details)
+			if err != nil {
+				return added, fmt.Errorf("unable to ingest job %s from dashboard %s: %v", jobName, dashboard, err)
+			}
+			added += n
+		}
+	}
+	return added, nil
+}
+
+func nestedBucket(parent *bolt.Bucket, names ...string) (*bolt.Bucket, error) {
+	b := parent
+	for _, name := range names {
+		var err error
+		if b, err = b.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Runs returns the runs of the job on the dashboard that started in [from, to), newest first.  A zero from or to
+// leaves that end of the range open.
+func (d *DB) Runs(dashboard, job string, from, to time.Time) ([]JobRun, error) {
+	runs := []JobRun{}
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		dashboardBucket := tx.Bucket(runsBucket).Bucket([]byte(dashboard))
+		if dashboardBucket == nil {
+			return fmt.Errorf("no runs recorded for dashboard %s", dashboard)
+		}
+		jobBucket := dashboardBucket.Bucket([]byte(job))
+		if jobBucket == nil {
+			return fmt.Errorf("no runs recorded for job %s on dashboard %s", job, dashboard)
+		}
+		return jobBucket.ForEach(func(k, v []byte) error {
+			record := runRecord{}
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			run := JobRun{
+				Dashboard:   dashboard,
+				Job:         job,
+				ChangeList:  string(k),
+				Timestamp:   record.Timestamp,
+				Query:       record.Query,
+				TestGridUrl: record.TestGridUrl,
+			}
+			t := run.Time()
+			if (!from.IsZero() && t.Before(from)) || (!to.IsZero() && !t.Before(to)) {
+				return nil
+			}
+			run.Tests = make(map[string]testgrid.TestStatus)
+			results := record.Results
+			for len(results) > 0 {
+				id, n := binary.Uvarint(results)
+				if n <= 0 {
+					return fmt.Errorf("corrupt results for run %s of job %s", run.ChangeList, job)
+				}
+				status, m := binary.Uvarint(results[n:])
+				if m <= 0 {
+					return fmt.Errorf("corrupt results for run %s of job %s", run.ChangeList, job)
+				}
+				results = results[n+m:]
+				run.Tests[testName(tx, id)] = testgrid.TestStatus(status)
+			}
+			runs = append(runs, run)
+			return nil
+		})
+	})
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Timestamp > runs[j].Timestamp
+	})
+	return runs, err
+}
+
+// JobDetails rebuilds the testgrid table for the job from the runs that started in [from, to), so the recorded
+// history can be analyzed exactly like data read from testgrid.
+func (d *DB) JobDetails(dashboard, job string, from, to time.Time) (testgrid.JobDetails, error) {
+	details := testgrid.JobDetails{
+		Name: job,
+	}
+	runs, err := d.Runs(dashboard, job, from, to)
+	if err != nil {
+		return details, err
+	}
+
+	names := make(map[string]bool)
+	for _, run := range runs {
+		details.Timestamps = append(details.Timestamps, run.Timestamp)
+		details.ChangeLists = append(details.ChangeLists, run.ChangeList)
+		details.Query = run.Query
+		details.TestGridUrl = run.TestGridUrl
+		for name := range run.Tests {
+			names[name] = true
+		}
+	}
+	sortedNames := []string{}
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		test := testgrid.Test{Name: name}
+		for _, run := range runs {
+			status := run.Tests[name]
+			last := len(test.Statuses) - 1
+			if last >= 0 && test.Statuses[last].Value == status {
+				test.Statuses[last].Count++
+			} else {
+				test.Statuses = append(test.Statuses, testgrid.TestResult{Count: 1, Value: status})
+			}
+		}
+		details.Tests = append(details.Tests, test)
+	}
+	return details, nil
+}
+
+func (d *DB) lastIngest() time.Time {
+	t := time.Time{}
+	d.bolt.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(metaBucket).Get(lastIngestKey); v != nil {
+			return t.UnmarshalText(v)
+		}
+		return nil
+	})
+	return t
+}
+
+func (d *DB) jobSummaries(dashboard string) (map[string]testgrid.JobSummary, error) {
+	jobs := make(map[string]testgrid.JobSummary)
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(summariesBucket).Bucket([]byte(dashboard))
+		if b == nil {
+			return fmt.Errorf("no jobs recorded for dashboard %s", dashboard)
+		}
+		return b.ForEach(func(k, v []byte) error {
+			summary := testgrid.JobSummary{}
+			if err := json.Unmarshal(v, &summary); err != nil {
+				return err
+			}
+			jobs[string(k)] = summary
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// NewDataSource serves all the runs recorded in the database at path as testgrid data, the analysis picks the runs
+// in its window from them.  The database is held open until the data source is closed.  Any number of processes may
+// read the database at once, but runs can only be ingested into it while none are.
+func NewDataSource(path string) (*DataSource, error) {
+	d, err := OpenReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DataSource{path: path, db: d}, nil
+}
+
+type DataSource struct {
+	path string
+
+	// lock is held for reading while the database is in use, so Close waits for the reads in progress
+	lock sync.RWMutex
+	db   *DB
+}
+
+// read calls f with the database.  Once the data source is closed each read opens the database for just that
+// read, so analyses that were still using the data source can complete.
+func (s *DataSource) read(f func(d *DB) error) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.db != nil {
+		return f(s.db)
+	}
+	d, err := OpenReadOnly(s.path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return f(d)
+}
+
+// Close releases the database.
+func (s *DataSource) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+func (s *DataSource) JobSummaries(dashboard string) (map[string]testgrid.JobSummary, time.Time, error) {
+	var jobs map[string]testgrid.JobSummary
+	var updated time.Time
+	err := s.read(func(d *DB) error {
+		var err error
+		jobs, err = d.jobSummaries(dashboard)
+		updated = d.lastIngest()
+		return err
+	})
+	if err != nil {
+		return map[string]testgrid.JobSummary{}, time.Time{}, err
+	}
+	return jobs, updated, nil
+}
+
+func (s *DataSource) JobDetails(dashboard, jobName string) (testgrid.JobDetails, error) {
+	details := testgrid.JobDetails{Name: jobName}
+	err := s.read(func(d *DB) error {
+		var err error
+		details, err = d.JobDetails(dashboard, jobName, time.Time{}, time.Time{})
+		return err
+	})
+	return details, err
+}
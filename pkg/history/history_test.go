@@ -0,0 +1,229 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bparees/sippy/pkg/testgrid"
+)
+
+const dashboard = "redhat-openshift-ocp-release-4.5-informing"
+
+var start = time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// hour is the testgrid timestamp of the run that started h hours after start.
+func hour(h int) int {
+	return int(start.Add(time.Duration(h)*time.Hour).Unix() * 1000)
+}
+
+// jobDetails are runs of a job at the hours, newest first as testgrid lists them.
+func jobDetails(changeLists []string, hours []int, tests ...testgrid.Test) testgrid.JobDetails {
+	details := testgrid.JobDetails{
+		Name:        "release-openshift-ocp-installer-e2e-aws-4.5",
+		Query:       "origin-ci-test/logs/release-openshift-ocp-installer-e2e-aws-4.5",
+		TestGridUrl: "https://testgrid.k8s.io/" + dashboard + "#release-openshift-ocp-installer-e2e-aws-4.5",
+		ChangeLists: changeLists,
+		Tests:       tests,
+	}
+	for _, h := range hours {
+		details.Timestamps = append(details.Timestamps, hour(h))
+	}
+	return details
+}
+
+func openDB(t *testing.T) (*DB, string, func()) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "history.db")
+	d, err := Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return d, path, func() {
+		d.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestIngest(t *testing.T) {
+	d, _, cleanup := openDB(t)
+	defer cleanup()
+
+	details := jobDetails([]string{"3", "2", "1"}, []int{3, 2, 1},
+		testgrid.Test{Name: "Overall", Statuses: []testgrid.TestResult{{Count: 1, Value: testgrid.Fail}, {Count: 2, Value: testgrid.Pass}}},
+		testgrid.Test{Name: "[sig-network] services should serve", Statuses: []testgrid.TestResult{{Count: 1, Value: testgrid.Fail}, {Count: 1, Value: testgrid.NoResult}, {Count: 1, Value: testgrid.Flaky}}},
+	)
+	added, err := d.Ingest(dashboard, testgrid.JobSummary{OverallStatus: "FAILING"}, details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 3 {
+		t.Errorf("expected 3 new runs, got %d", added)
+	}
+
+	runs, err := d.Runs(dashboard, details.Name, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]testgrid.TestStatus{
+		{"Overall": testgrid.Fail, "[sig-network] services should serve": testgrid.Fail},
+		{"Overall": testgrid.Pass},
+		{"Overall": testgrid.Pass, "[sig-network] services should serve": testgrid.Flaky},
+	}
+	if len(runs) != len(expected) {
+		t.Fatalf("expected %d runs, got %d", len(expected), len(runs))
+	}
+	for i, run := range runs {
+		if run.ChangeList != details.ChangeLists[i] || run.Timestamp != details.Timestamps[i] || run.Query != details.Query || run.TestGridUrl != details.TestGridUrl {
+			t.Errorf("expected run %d to be changelist %s at %d, got %+v", i, details.ChangeLists[i], details.Timestamps[i], run)
+		}
+		if !reflect.DeepEqual(run.Tests, expected[i]) {
+			t.Errorf("expected the tests of run %s to be %v, got %v", run.ChangeList, expected[i], run.Tests)
+		}
+	}
+
+	// the runs rebuild the table they were ingested from, with the tests sorted by name
+	rebuilt, err := d.JobDetails(dashboard, details.Name, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rebuilt, details) {
+		t.Errorf("expected the job details to round trip as\n%+v\ngot\n%+v", details, rebuilt)
+	}
+
+	jobs, err := d.jobSummaries(dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(jobs, map[string]testgrid.JobSummary{details.Name: {OverallStatus: "FAILING"}}) {
+		t.Errorf("expected the summary of the job to be recorded, got %+v", jobs)
+	}
+	if d.lastIngest().IsZero() {
+		t.Errorf("expected the time of the ingest to be recorded")
+	}
+}
+
+func TestIngestOverlapping(t *testing.T) {
+	d, _, cleanup := openDB(t)
+	defer cleanup()
+
+	first := jobDetails([]string{"2", "1"}, []int{2, 1},
+		testgrid.Test{Name: "Overall", Statuses: []testgrid.TestResult{{Count: 1, Value: testgrid.Running}, {Count: 1, Value: testgrid.Pass}}},
+	)
+	if _, err := d.Ingest(dashboard, testgrid.JobSummary{}, first); err != nil {
+		t.Fatal(err)
+	}
+	// the next fetch repeats the runs testgrid still lists, and the run that was still running has completed
+	second := jobDetails([]string{"3", "2", "1"}, []int{3, 2, 1},
+		testgrid.Test{Name: "Overall", Statuses: []testgrid.TestResult{{Count: 1, Value: testgrid.Pass}, {Count: 1, Value: testgrid.Fail}, {Count: 1, Value: testgrid.Pass}}},
+	)
+	added, err := d.Ingest(dashboard, testgrid.JobSummary{}, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Errorf("expected 1 new run, got %d", added)
+	}
+
+	rebuilt, err := d.JobDetails(dashboard, second.Name, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rebuilt, second) {
+		t.Errorf("expected the repeated runs to be updated in place as\n%+v\ngot\n%+v", second, rebuilt)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	d, _, cleanup := openDB(t)
+	defer cleanup()
+
+	details := jobDetails([]string{"4", "3", "2", "1"}, []int{4, 3, 2, 1},
+		testgrid.Test{Name: "Overall", Statuses: []testgrid.TestResult{{Count: 4, Value: testgrid.Pass}}},
+	)
+	if _, err := d.Ingest(dashboard, testgrid.JobSummary{}, details); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		from, to    time.Time
+		changeLists []string
+	}{
+		{name: "all", changeLists: []string{"4", "3", "2", "1"}},
+		{name: "from is inclusive", from: time.Unix(0, int64(hour(2))*int64(time.Millisecond)), changeLists: []string{"4", "3", "2"}},
+		{name: "to is exclusive", to: time.Unix(0, int64(hour(3))*int64(time.Millisecond)), changeLists: []string{"2", "1"}},
+		{name: "between", from: start.Add(90 * time.Minute), to: start.Add(210 * time.Minute), changeLists: []string{"3", "2"}},
+		{name: "none", from: start.Add(5 * time.Hour), changeLists: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs, err := d.Runs(dashboard, details.Name, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changeLists := []string{}
+			for _, run := range runs {
+				changeLists = append(changeLists, run.ChangeList)
+			}
+			if !reflect.DeepEqual(changeLists, tt.changeLists) {
+				t.Errorf("expected runs %v, got %v", tt.changeLists, changeLists)
+			}
+		})
+	}
+
+	if _, err := d.Runs("unknown-dashboard", details.Name, time.Time{}, time.Time{}); err == nil {
+		t.Errorf("expected an error for a dashboard with no runs")
+	}
+	if _, err := d.Runs(dashboard, "unknown-job", time.Time{}, time.Time{}); err == nil {
+		t.Errorf("expected an error for a job with no runs")
+	}
+}
+
+func TestDataSource(t *testing.T) {
+	d, path, cleanup := openDB(t)
+	defer cleanup()
+
+	details := jobDetails([]string{"2", "1"}, []int{2, 1},
+		testgrid.Test{Name: "Overall", Statuses: []testgrid.TestResult{{Count: 1, Value: testgrid.Fail}, {Count: 1, Value: testgrid.Pass}}},
+	)
+	if _, err := d.Ingest(dashboard, testgrid.JobSummary{OverallStatus: "FLAKY"}, details); err != nil {
+		t.Fatal(err)
+	}
+	// the data source can't read the database while it is open for ingesting
+	d.Close()
+
+	source, err := NewDataSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(when string) {
+		jobs, updated, err := source.JobSummaries(dashboard)
+		if err != nil {
+			t.Fatalf("%s: %v", when, err)
+		}
+		if jobs[details.Name].OverallStatus != "FLAKY" || updated.IsZero() {
+			t.Errorf("%s: expected the summary of the job and the time of the ingest, got %+v at %s", when, jobs, updated)
+		}
+		rebuilt, err := source.JobDetails(dashboard, details.Name)
+		if err != nil {
+			t.Fatalf("%s: %v", when, err)
+		}
+		if !reflect.DeepEqual(rebuilt, details) {
+			t.Errorf("%s: expected job details\n%+v\ngot\n%+v", when, details, rebuilt)
+		}
+	}
+	check("open")
+	if err := source.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// analyses that were still using the data source when it was replaced can complete
+	check("closed")
+}
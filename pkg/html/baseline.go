@@ -0,0 +1,103 @@
+package html
+
+import (
+	"fmt"
+	gohtml "html"
+	"net/http"
+	"text/template"
+
+	"k8s.io/klog"
+
+	"github.com/bparees/sippy/pkg/util"
+)
+
+const (
+	baselinePageHtml = `
+<h1 class=text-center>{{ .Release }} Compared To {{ .Baseline }}</h1>
+
+<p class="small mb-3">
+	Jump to: <a href="#BaselineSummary">Summary</a> | <a href="#BaselineVariants">Job Pass Rates By Variant</a> |
+	         <a href="#BaselineJobs">Job Pass Rates By Job Name</a> | <a href="#BaselineTests">Top Test Regressions</a>
+</p>
+
+{{ baselineTable "BaselineSummary" "Summary" "Overall pass rates compared to the same period before the baseline was taken." (baselineSummary .) .Baseline .TestCount }}
+
+{{ baselineTable "BaselineVariants" "Job Pass Rates By Variant" "Aggregation of all job runs for each value of each variant dimension, such as cloud=aws, largest regressions first." .Variants .Baseline .TestCount }}
+
+{{ baselineTable "BaselineJobs" "Job Pass Rates By Job Name" "Pass rate of each job, matched to the same job in the baseline release, largest regressions first." .Jobs .Baseline .TestCount }}
+
+{{ baselineTable "BaselineTests" "Top Test Regressions" "Tests whose pass rate dropped the most since the baseline." .Tests .Baseline .TestCount }}
+`
+)
+
+type baselinePage struct {
+	util.BaselineComparison
+	TestCount int
+}
+
+// comparisonArrow marks the change from the baseline pass rate.
+func comparisonArrow(c util.Comparison) string {
+	if c.Baseline == nil {
+		return ""
+	}
+	return passRateArrow(c.Current.Successes, c.Current.Failures, c.Baseline.Successes, c.Baseline.Failures)
+}
+
+func baselineSummary(comparison baselinePage) []util.Comparison {
+	return []util.Comparison{comparison.TestPassRate, comparison.JobPassRate}
+}
+
+// baselineTable lists up to count comparisons, which are already sorted with the largest regressions first.
+func baselineTable(id, title, description string, comparisons []util.Comparison, baseline string, count int) string {
+	s := fmt.Sprintf(`
+	<table class="table">
+		<tr>
+			<th colspan=4 class="text-center"><a class="text-dark" title="%[3]s" id="%[1]s" href="#%[1]s">%[2]s</a></th>
+		</tr>
+		<tr>
+			<th>Name</th><th>Current</th><th/><th>%[4]s</th>
+		</tr>
+	`, id, title, description, baseline)
+
+	rowTemplate := `
+		<tr>
+			<td>%s</td>
+			<td>%0.2f%% <span class="text-nowrap">(%d runs)</span></td>
+			<td>%s</td>
+			<td>%s</td>
+		</tr>
+	`
+	for i, c := range comparisons {
+		if i == count {
+			s += fmt.Sprintf(`<tr><td colspan=4>Plus %d more</td></tr>`, len(comparisons)-count)
+			break
+		}
+		prev := "NA"
+		if c.Baseline != nil {
+			prev = fmt.Sprintf(`%0.2f%% <span class="text-nowrap">(%d runs)</span>`, c.Baseline.PassPercentage, c.Baseline.Runs())
+		}
+		s += fmt.Sprintf(rowTemplate, gohtml.EscapeString(c.Name), c.Current.PassPercentage, c.Current.Runs(), comparisonArrow(c), prev)
+	}
+	s = s + "</table>"
+	return s
+}
+
+// PrintBaselineReport renders the comparison of a release to a historical baseline, showing at most count tests
+// and jobs.
+func PrintBaselineReport(w http.ResponseWriter, req *http.Request, comparison util.BaselineComparison, count int) {
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	fmt.Fprintf(w, htmlPageStart, "Release Baseline Comparison")
+
+	var page = template.Must(template.New("baselinePage").Funcs(
+		template.FuncMap{
+			"baselineTable":   baselineTable,
+			"baselineSummary": baselineSummary,
+		},
+	).Parse(baselinePageHtml))
+
+	if err := page.Execute(w, baselinePage{comparison, count}); err != nil {
+		klog.Errorf("Unable to render page: %v", err)
+	}
+
+	fmt.Fprintf(w, htmlPageEnd, comparison.Timestamp.Format("Jan 2 15:04 2006 MST"))
+}
@@ -2,7 +2,8 @@ package html
 
 import (
 	"fmt"
-	//"html"
+	gohtml "html"
+	"math"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -11,6 +12,7 @@ import (
 
 	"k8s.io/klog"
 
+	"github.com/bparees/sippy/pkg/knownissues"
 	"github.com/bparees/sippy/pkg/util"
 )
 
@@ -19,10 +21,10 @@ var (
 )
 
 const (
-	up       = `<i class="fa fa-arrow-up" title="Increased %0.2f%%" style="font-size:28px;color:green"></i>`
-	down     = `<i class="fa fa-arrow-down" title="Decreased %0.2f%%" style="font-size:28px;color:red"></i>`
-	flatup   = `<i class="fa fa-arrows-h" title="Increased %0.2f%%" style="font-size:28px;color:darkgray"></i>`
-	flatdown = `<i class="fa fa-arrows-h" title="Decreased %0.2f%%" style="font-size:28px;color:darkgray"></i>`
+	up       = `<i class="fa fa-arrow-up" title="%s" style="font-size:28px;color:green"></i>`
+	down     = `<i class="fa fa-arrow-down" title="%s" style="font-size:28px;color:red"></i>`
+	flatup   = `<i class="fa fa-arrows-h" title="%s" style="font-size:28px;color:darkgray"></i>`
+	flatdown = `<i class="fa fa-arrows-h" title="%s" style="font-size:28px;color:darkgray"></i>`
 	flat     = `<i class="fa fa-arrows-h" style="font-size:28px;color:darkgray"></i>`
 
 	htmlPageStart = `
@@ -69,20 +71,26 @@ Data current as of: %s
 
 <p class="small mb-3">
 	Jump to: <a href="#SummaryAcrossAllJobs">Summary Across All Jobs</a> | <a href="#FailureGroupings">Failure Groupings</a> | 
-	         <a href="#JobPassRatesByPlatform">Job Pass Rates By Platform</a> | <a href="#TopFailingTests">Top Failing Tests</a> | 
-	         <a href="#JobPassRatesByJobName">Job Pass Rates By Job Name</a> | <a href="#CanaryTestFailures">Canary Test Failures</a> |
+	         <a href="#JobPassRatesByVariant">Job Pass Rates By Variant</a> | <a href="#TopFailingTests">Top Failing Tests</a> | <a href="#TopFlakyTests">Top Flaky Tests</a> | 
+	         <a href="#KnownIssues">Known Issues</a> | <a href="#JobPassRatesByJobName">Job Pass Rates By Job Name</a> | <a href="#CanaryTestFailures">Canary Test Failures</a> |
 	         <a href="#JobRunsWithFailureGroups">Job Runs With Failure Groups</a>
 </p>
 
-{{ summaryAcrossAllJobs .Current.All .Prev.All .EndDay }}
+{{ settings .Settings }}
 
-{{ failureGroups .Current.FailureGroups .Prev.FailureGroups .EndDay }}
+{{ summaryAcrossAllJobs .Current.All .Prev.All .Periods }}
 
-{{ summaryJobsByPlatform .Current .Prev .EndDay .JobTestCount }}
+{{ failureGroups .Current.FailureGroups .Prev.FailureGroups .Periods }}
 
-{{ summaryTopFailingTests .Current.TopFailingTestsWithoutBug .Current.TopFailingTestsWithBug .Prev.All .EndDay }}
+{{ summaryJobsByVariant .Current .Prev .Periods .Rules .JobTestCount }}
 
-{{ summaryJobPassRatesByJobName .Current .Prev .EndDay .JobTestCount }}
+{{ summaryTopFailingTests .Current.TopFailingTestsWithoutBug .Current.TopFailingTestsWithBug .Current.TopFailingTestsWithKnownIssue .Prev.All .Periods .Trends }}
+
+{{ summaryTopFlakyTests .Current.Flakes .Prev.All .Periods }}
+
+{{ knownIssues .Current.KnownIssues }}
+
+{{ summaryJobPassRatesByJobName .Current .Prev .Periods .Trends .Rules .JobTestCount }}
 
 {{ canaryTestFailures .Current.All }}
 
@@ -107,7 +115,35 @@ Data current as of: %s
 	`
 )
 
-func summaryAcrossAllJobs(result, resultPrev map[string]util.SortedAggregateTestResult, endDay int) string {
+// passRateArrow marks the change from the previous pass rate.  The arrow only points up or down when the change is
+// statistically significant, the tooltip has the confidence interval and p-value of the change.
+func passRateArrow(successes, failures, prevSuccesses, prevFailures int) string {
+	sig := util.ComparePassRates(successes, failures, prevSuccesses, prevFailures)
+	direction := "Increased"
+	if sig.Delta < 0 {
+		direction = "Decreased"
+	}
+	test := "z-test"
+	if sig.Exact {
+		test = "Fisher's exact test"
+	}
+	title := fmt.Sprintf("%s %0.2f%% (95%% confidence interval %+0.2f%% to %+0.2f%%, p=%0.3g by %s)", direction, math.Abs(sig.Delta), sig.Low, sig.High, sig.PValue, test)
+	if !sig.Significant {
+		title += ", not significant"
+	}
+
+	switch {
+	case sig.Significant && sig.Delta > 0:
+		return fmt.Sprintf(up, title)
+	case sig.Significant && sig.Delta < 0:
+		return fmt.Sprintf(down, title)
+	case sig.Delta > 0:
+		return fmt.Sprintf(flatup, title)
+	}
+	return fmt.Sprintf(flatdown, title)
+}
+
+func summaryAcrossAllJobs(result, resultPrev map[string]util.SortedAggregateTestResult, periods periods) string {
 
 	all := result["all"]
 	allPrev := resultPrev["all"]
@@ -118,7 +154,7 @@ func summaryAcrossAllJobs(result, resultPrev map[string]util.SortedAggregateTest
 			<th colspan=3 class="text-center"><a class="text-dark" id="SummaryAcrossAllJobs" href="#SummaryAcrossAllJobs">Summary Across All Jobs</a></th>			
 		</tr>
 		<tr>
-			<th/><th>Latest %d days</th><th>Previous 7 days</th>
+			<th/><th>%s</th><th>%s</th>
 		</tr>
 		<tr>
 			<td>Test executions: </td><td>%d</td><td>%d</td>
@@ -126,12 +162,19 @@ func summaryAcrossAllJobs(result, resultPrev map[string]util.SortedAggregateTest
 		<tr>
 			<td>Test Pass Percentage: </td><td>%0.2f</td><td>%0.2f</td>
 		</tr>
+		<tr>
+			<td title="Test runs that passed after being retried.  These are included in the pass percentage.">Flaky test executions: </td><td>%d</td><td>%d</td>
+		</tr>
+		<tr>
+			<td title="Test runs that timed out.  These are included in the pass percentage as failures.">Timed out test executions: </td><td>%d</td><td>%d</td>
+		</tr>
 	</table>`
-	s := fmt.Sprintf(summary, endDay, all.Successes+all.Failures, allPrev.Successes+allPrev.Failures, all.TestPassPercentage, allPrev.TestPassPercentage)
+	s := fmt.Sprintf(summary, periods.Current, periods.Prev, all.Successes+all.Failures, allPrev.Successes+allPrev.Failures, all.TestPassPercentage, allPrev.TestPassPercentage,
+		all.Flakes, allPrev.Flakes, all.Timeouts, allPrev.Timeouts)
 	return s
 }
 
-func failureGroups(failureGroups, failureGroupsPrev []util.JobRunResult, endDay int) string {
+func failureGroups(failureGroups, failureGroupsPrev []util.JobRunResult, periods periods) string {
 	count, countPrev, median, medianPrev, avg, avgPrev := 0, 0, 0, 0, 0, 0
 	for _, group := range failureGroups {
 		count += group.TestFailures
@@ -145,7 +188,7 @@ func failureGroups(failureGroups, failureGroupsPrev []util.JobRunResult, endDay
 	}
 	if len(failureGroupsPrev) != 0 {
 		medianPrev = failureGroupsPrev[len(failureGroupsPrev)/2].TestFailures
-		avgPrev = count / len(failureGroupsPrev)
+		avgPrev = countPrev / len(failureGroupsPrev)
 	}
 
 	groups := `
@@ -154,7 +197,7 @@ func failureGroups(failureGroups, failureGroupsPrev []util.JobRunResult, endDay
 			<th colspan=3 class="text-center"><a class="text-dark" title="Statistics on how often we see a cluster of test failures in a single run.  Such clusters are indicative of cluster infrastructure problems that impact many tests and should be investigated.  See below for a link to specific jobs that show large clusters of test failures."  id="FailureGroupings" href="#FailureGroupings">Failure Groupings</a></th>
 		</tr>
 		<tr>
-			<th/><th>Latest %d days</th><th>Previous 7 days</th>
+			<th/><th>%s</th><th>%s</th>
 		</tr>
 		<tr>
 			<td>Job Runs with a Failure Group: </td><td>%d</td><td>%d</td>
@@ -166,39 +209,48 @@ func failureGroups(failureGroups, failureGroupsPrev []util.JobRunResult, endDay
 			<td>Median Failure Group Size: </td><td>%d</td><td>%d</td>
 		</tr>
 	</table>`
-	s := fmt.Sprintf(groups, endDay, len(failureGroups), len(failureGroupsPrev), avg, avgPrev, median, medianPrev)
+	s := fmt.Sprintf(groups, periods.Current, periods.Prev, len(failureGroups), len(failureGroupsPrev), avg, avgPrev, median, medianPrev)
 	return s
 }
 
-func getPrevPlatform(platform string, jobsByPlatform []util.JobResult) *util.JobResult {
-	for _, v := range jobsByPlatform {
-		if v.Platform == platform {
+func getPrevVariant(variant string, jobsByVariant []util.JobResult) *util.JobResult {
+	for _, v := range jobsByVariant {
+		if v.Variant == variant {
 			return &v
 		}
 	}
 	return nil
 }
 
-func summaryJobsByPlatform(report, reportPrev util.TestReport, endDay, jobTestCount int) string {
-	jobsByPlatform := util.SummarizeJobsByPlatform(report)
-	jobsByPlatformPrev := util.SummarizeJobsByPlatform(reportPrev)
+// variantClass is the class of the collapsed test rows of a variant value, the same value can be in several
+// dimensions.
+func variantClass(dimension, variant string) string {
+	return strings.ReplaceAll("variant-"+dimension+"-"+variant, ".", "")
+}
 
+func summaryJobsByVariant(report, reportPrev util.TestReport, periods periods, rules *util.Rules, jobTestCount int) string {
 	s := fmt.Sprintf(`
 	<table class="table">
 		<tr>
-			<th colspan=4 class="text-center"><a class="text-dark" title="Aggregation of all job runs for a given platform, sorted by passing rate percentage.  Platforms at the top of this list have unreliable CI jobs or the product is unreliable on those platforms." id="JobPassRatesByPlatform" href="#JobPassRatesByPlatform">Job Pass Rates By Platform</a></th>
+			<th colspan=4 class="text-center"><a class="text-dark" title="Aggregation of all job runs for each value of each variant dimension, such as cloud or arch, sorted by passing rate percentage.  Variants at the top of each dimension have unreliable CI jobs or the product is unreliable on those variants." id="JobPassRatesByVariant" href="#JobPassRatesByVariant">Job Pass Rates By Variant</a></th>
 		</tr>
 		<tr>
-			<th>Platform</th><th>Latest %d days</th><th/><th>Previous 7 days</th>
+			<th>Variant</th><th>%s</th><th/><th>%s</th>
 		</tr>
-	`, endDay)
+	`, periods.Current, periods.Prev)
+
+	dimensionTemplate := `
+		<tr>
+			<td colspan=4 class="font-weight-bold bg-light">%s</td>
+		</tr>
+	`
 
 	jobGroupTemplate := `
 		<tr>
 			<td>
 				%[1]s
 				<p>
-				<button class="btn btn-primary btn-sm py-0" style="font-size: 0.8em" type="button" data-toggle="collapse" data-target=".%[1]s" aria-expanded="false" aria-controls="%[1]s">Expand Failing Tests</button>
+				<button class="btn btn-primary btn-sm py-0" style="font-size: 0.8em" type="button" data-toggle="collapse" data-target=".%[2]s" aria-expanded="false" aria-controls="%[2]s">Expand Failing Tests</button>
 			</td>
 			<td>
 				%0.2f%% <span class="text-nowrap">(%d runs)</span>
@@ -217,7 +269,7 @@ func summaryJobsByPlatform(report, reportPrev util.TestReport, endDay, jobTestCo
 				<td>
 					%[1]s
 					<p>
-					<button class="btn btn-primary btn-sm py-0" style="font-size: 0.8em" type="button" data-toggle="collapse" data-target=".%[1]s" aria-expanded="false" aria-controls="%[1]s">Expand Failing Tests</button>
+					<button class="btn btn-primary btn-sm py-0" style="font-size: 0.8em" type="button" data-toggle="collapse" data-target=".%[2]s" aria-expanded="false" aria-controls="%[2]s">Expand Failing Tests</button>
 				</td>
 				<td>
 					%0.2f%% <span class="text-nowrap">(%d runs)</span>
@@ -229,70 +281,67 @@ func summaryJobsByPlatform(report, reportPrev util.TestReport, endDay, jobTestCo
 			</tr>
 		`
 
-	for _, v := range jobsByPlatform {
-		prev := getPrevPlatform(v.Platform, jobsByPlatformPrev)
-		p := util.Percent(v.Successes, v.Failures)
-		if prev != nil {
-			pprev := util.Percent(prev.Successes, prev.Failures)
-			arrow := ""
-			delta := 5.0
-			if v.Successes+v.Failures > 80 {
-				delta = 2
-			}
-			if p > pprev+delta {
-				arrow = fmt.Sprintf(up, p-pprev)
-			} else if p < pprev-delta {
-				arrow = fmt.Sprintf(down, pprev-p)
-			} else if p > pprev {
-				arrow = fmt.Sprintf(flatup, p-pprev)
+	for _, dimension := range rules.VariantDimensions() {
+		jobsByVariant := util.SummarizeJobsByVariant(report, dimension)
+		if len(jobsByVariant) == 0 {
+			continue
+		}
+		jobsByVariantPrev := util.SummarizeJobsByVariant(reportPrev, dimension)
+		s = s + fmt.Sprintf(dimensionTemplate, dimension)
+
+		for _, v := range jobsByVariant {
+			class := variantClass(dimension, v.Variant)
+			prev := getPrevVariant(v.Variant, jobsByVariantPrev)
+			p := util.Percent(v.Successes, v.Failures)
+			if prev != nil {
+				pprev := util.Percent(prev.Successes, prev.Failures)
+				arrow := passRateArrow(v.Successes, v.Failures, prev.Successes, prev.Failures)
+				s = s + fmt.Sprintf(jobGroupTemplate, v.Variant, class,
+					p,
+					v.Successes+v.Failures,
+					arrow,
+					pprev,
+					prev.Successes+prev.Failures,
+				)
 			} else {
-				arrow = fmt.Sprintf(flatdown, pprev-p)
+				s = s + fmt.Sprintf(naTemplate, v.Variant, class,
+					p,
+					v.Successes+v.Failures,
+				)
 			}
-			s = s + fmt.Sprintf(jobGroupTemplate, v.Platform,
-				p,
-				v.Successes+v.Failures,
-				arrow,
-				pprev,
-				prev.Successes+prev.Failures,
-			)
-		} else {
-			s = s + fmt.Sprintf(naTemplate, v.Platform,
-				p,
-				v.Successes+v.Failures,
-			)
-		}
 
-		platformTests := report.ByPlatform[v.Platform]
-		count := jobTestCount
-		rowCount := 0
-		rows := ""
-		additionalMatches := 0
-		for _, test := range platformTests.TestResults {
-			if util.IgnoreTestRegex.MatchString(test.Name) {
-				continue
+			variantTests := report.ByVariant[dimension][v.Variant]
+			count := jobTestCount
+			rowCount := 0
+			rows := ""
+			additionalMatches := 0
+			for _, test := range variantTests.TestResults {
+				if rules.IgnoreTest(test.Name) {
+					continue
+				}
+				if count == 0 {
+					additionalMatches++
+					continue
+				}
+				count--
+
+				encodedTestName := url.QueryEscape(regexp.QuoteMeta(test.Name))
+
+				rows = rows + fmt.Sprintf(testGroupTemplate, class, test.Name, v.Variant, report.Release, encodedTestName,
+					test.PassPercentage,
+					test.Successes+test.Failures,
+				)
+				rowCount++
 			}
-			if count == 0 {
-				additionalMatches++
-				continue
+			if additionalMatches > 0 {
+				rows += fmt.Sprintf(`<tr class="collapse %s"><td colspan=2>Plus %d more tests</td></tr>`, class, additionalMatches)
+			}
+			if rowCount > 0 {
+				s = s + fmt.Sprintf(`<tr class="collapse %s"><td colspan=2 class="font-weight-bold">Test Name</td><td class="font-weight-bold">Test Pass Rate</td></tr>`, class)
+				s = s + rows
+			} else {
+				s = s + fmt.Sprintf(`<tr class="collapse %s"><td colspan=3 class="font-weight-bold">No Tests Matched Filters</td></tr>`, class)
 			}
-			count--
-
-			encodedTestName := url.QueryEscape(regexp.QuoteMeta(test.Name))
-
-			rows = rows + fmt.Sprintf(testGroupTemplate, strings.ReplaceAll(v.Platform, ".", ""), test.Name, v.Platform, report.Release, encodedTestName,
-				test.PassPercentage,
-				test.Successes+test.Failures,
-			)
-			rowCount++
-		}
-		if additionalMatches > 0 {
-			rows += fmt.Sprintf(`<tr class="collapse %s"><td colspan=2>Plus %d more tests</td></tr>`, v.Platform, additionalMatches)
-		}
-		if rowCount > 0 {
-			s = s + fmt.Sprintf(`<tr class="collapse %s"><td colspan=2 class="font-weight-bold">Test Name</td><td class="font-weight-bold">Test Pass Rate</td></tr>`, v.Platform)
-			s = s + rows
-		} else {
-			s = s + fmt.Sprintf(`<tr class="collapse %s"><td colspan=3 class="font-weight-bold">No Tests Matched Filters</td></tr>`, v.Platform)
 		}
 	}
 	s = s + "</table>"
@@ -308,31 +357,39 @@ func getPrevTest(test string, testResults []util.TestResult) *util.TestResult {
 	return nil
 }
 
-func summaryTopFailingTests(topFailingTestsWithoutBug, topFailingTestsWithBug []*util.TestResult, resultPrev map[string]util.SortedAggregateTestResult, endDay int) string {
+// flakesAndTimeouts notes how many of the test's runs flaked or timed out, if any did.
+func flakesAndTimeouts(test *util.TestResult) string {
+	if test.Flakes == 0 && test.Timeouts == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`<br><span class="small text-muted text-nowrap">%d flakes, %d timeouts</span>`, test.Flakes, test.Timeouts)
+}
+
+func summaryTopFailingTests(topFailingTestsWithoutBug, topFailingTestsWithBug, topFailingTestsWithKnownIssue []*util.TestResult, resultPrev map[string]util.SortedAggregateTestResult, periods periods, trends util.Trends) string {
 	allPrev := resultPrev["all"]
 
-	// test name | bug | pass rate | higher/lower | pass rate
+	// test name | bug | pass rate | higher/lower | pass rate | trend
 	s := fmt.Sprintf(`
 	<table class="table">
 		<tr>
-			<th colspan=5 class="text-center"><a class="text-dark" title="Most frequently failing tests without a known bug, sorted by passing rate.  The link will prepopulate a BZ template to be filled out and submitted to report a bug against the test." id="TopFailingTests" href="#TopFailingTests">Top Failing Tests Without A Bug</a></th>
+			<th colspan=6 class="text-center"><a class="text-dark" title="Most frequently failing tests without a known bug, sorted by passing rate.  The link will prepopulate a BZ template to be filled out and submitted to report a bug against the test." id="TopFailingTests" href="#TopFailingTests">Top Failing Tests Without A Bug</a></th>
 		</tr>
 		<tr>
-			<th colspan=2/><th class="text-center">Latest %d Days</th><th/><th class="text-center">Previous 7 Days</th>
+			<th colspan=2/><th class="text-center">%s</th><th/><th class="text-center">%s</th><th/>
 		</tr>
 		<tr>
-			<th>Test Name</th><th>File a Bug</th><th>Pass Rate</th><th/><th>Pass Rate</th>
+			<th>Test Name</th><th>File a Bug</th><th>Pass Rate</th><th/><th>Pass Rate</th><th>Trend</th>
 		</tr>
-	`, endDay)
+	`, periods.Current, periods.Prev)
 
 	template := `
 		<tr>
-			<td>%s</td><td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span></td><td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span></td>
+			<td>%s</td><td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span>%s</td><td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span></td><td>%s</td>
 		</tr>
 	`
 	naTemplate := `
 		<tr>
-			<td>%s</td><td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span></td><td/><td>NA</td>
+			<td>%s</td><td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span>%s</td><td/><td>NA</td><td>%s</td>
 		</tr>
 	`
 
@@ -351,33 +408,19 @@ func summaryTopFailingTests(topFailingTestsWithoutBug, topFailingTestsWithBug []
 		}
 
 		if testPrev != nil {
-			arrow := ""
-			delta := 5.0
-			if test.Successes+test.Failures > 80 {
-				delta = 2
-			}
-
-			if test.PassPercentage > testPrev.PassPercentage+delta {
-				arrow = fmt.Sprintf(up, test.PassPercentage-testPrev.PassPercentage)
-			} else if test.PassPercentage < testPrev.PassPercentage-delta {
-				arrow = fmt.Sprintf(down, testPrev.PassPercentage-test.PassPercentage)
-			} else if test.PassPercentage > testPrev.PassPercentage {
-				arrow = fmt.Sprintf(flatup, test.PassPercentage-testPrev.PassPercentage)
-			} else {
-				arrow = fmt.Sprintf(flatdown, testPrev.PassPercentage-test.PassPercentage)
-			}
+			arrow := passRateArrow(test.Successes, test.Failures, testPrev.Successes, testPrev.Failures)
 
-			s += fmt.Sprintf(template, testLink, bug, test.PassPercentage, test.Successes+test.Failures, arrow, testPrev.PassPercentage, testPrev.Successes+testPrev.Failures)
+			s += fmt.Sprintf(template, testLink, bug, test.PassPercentage, test.Successes+test.Failures, flakesAndTimeouts(test), arrow, testPrev.PassPercentage, testPrev.Successes+testPrev.Failures, sparkline(trends.Tests[test.Name]))
 		} else {
-			s += fmt.Sprintf(naTemplate, testLink, bug, test.PassPercentage, test.Successes+test.Failures)
+			s += fmt.Sprintf(naTemplate, testLink, bug, test.PassPercentage, test.Successes+test.Failures, flakesAndTimeouts(test), sparkline(trends.Tests[test.Name]))
 		}
 	}
 
 	s += `<tr>
-			<th colspan=5 class="text-center"><a class="text-dark" title="Most frequently failing tests with a known bug, sorted by passing rate.">Top Failing Tests With A Bug</a></th>
+			<th colspan=6 class="text-center"><a class="text-dark" title="Most frequently failing tests with a known bug, sorted by passing rate.">Top Failing Tests With A Bug</a></th>
 		  </tr>
 		<tr>
-			<th>Test Name</th><th>BZ</th><th>Pass Rate</th><th/><th>Pass Rate</th>
+			<th>Test Name</th><th>BZ</th><th>Pass Rate</th><th/><th>Pass Rate</th><th>Trend</th>
 		</tr>`
 
 	for _, test := range topFailingTestsWithBug {
@@ -393,33 +436,118 @@ func summaryTopFailingTests(topFailingTestsWithoutBug, topFailingTestsWithBug []
 			bug += fmt.Sprintf("<a target=\"_blank\" href=%s>%s</a> ", b, bugID)
 		}
 		if testPrev != nil {
-			arrow := ""
-			delta := 5.0
-			if test.Successes+test.Failures > 80 {
-				delta = 2
-			}
-			if test.PassPercentage > testPrev.PassPercentage+delta {
-				arrow = up
-			} else if test.PassPercentage < testPrev.PassPercentage-delta {
-				arrow = down
-			}
+			arrow := passRateArrow(test.Successes, test.Failures, testPrev.Successes, testPrev.Failures)
 
-			if test.PassPercentage > testPrev.PassPercentage+delta {
-				arrow = fmt.Sprintf(up, test.PassPercentage-testPrev.PassPercentage)
-			} else if test.PassPercentage < testPrev.PassPercentage-delta {
-				arrow = fmt.Sprintf(down, testPrev.PassPercentage-test.PassPercentage)
-			} else if test.PassPercentage > testPrev.PassPercentage {
-				arrow = fmt.Sprintf(flatup, test.PassPercentage-testPrev.PassPercentage)
-			} else {
-				arrow = fmt.Sprintf(flatdown, testPrev.PassPercentage-test.PassPercentage)
-			}
+			s += fmt.Sprintf(template, testLink, bug, test.PassPercentage, test.Successes+test.Failures, flakesAndTimeouts(test), arrow, testPrev.PassPercentage, testPrev.Successes+testPrev.Failures, sparkline(trends.Tests[test.Name]))
+		} else {
+			s += fmt.Sprintf(naTemplate, testLink, bug, test.PassPercentage, test.Successes+test.Failures, flakesAndTimeouts(test), sparkline(trends.Tests[test.Name]))
+		}
+	}
+
+	s += `<tr>
+			<th colspan=6 class="text-center"><a class="text-dark" title="Most frequently failing tests that match a known issue, sorted by passing rate.  They are tracked by the bug of the issue until it expires." id="TopFailingTestsWithKnownIssue" href="#TopFailingTestsWithKnownIssue">Top Failing Tests With A Known Issue</a></th>
+		  </tr>
+		<tr>
+			<th>Test Name</th><th>Known Issue</th><th>Pass Rate</th><th/><th>Pass Rate</th><th>Trend</th>
+		</tr>`
+
+	for _, test := range topFailingTestsWithKnownIssue {
+		encodedTestName := url.QueryEscape(regexp.QuoteMeta(test.Name))
+
+		testLink := fmt.Sprintf("<a target=\"_blank\" href=\"https://search.svc.ci.openshift.org/?maxAge=168h&context=1&type=bug%%2Bjunit&name=&maxMatches=5&maxBytes=20971520&groupBy=job&search=%s\">%s</a>", encodedTestName, test.Name)
+		testPrev := getPrevTest(test.Name, allPrev.TestResults)
+
+		issue := test.KnownIssue
+		bug := fmt.Sprintf("<a target=\"_blank\" href=%s>%s</a> <span class=\"small text-muted text-nowrap\">until %s</span>", issue.BugURL(), issue.BugID(), issue.Expires)
+		if testPrev != nil {
+			arrow := passRateArrow(test.Successes, test.Failures, testPrev.Successes, testPrev.Failures)
 
-			s += fmt.Sprintf(template, testLink, bug, test.PassPercentage, test.Successes+test.Failures, arrow, testPrev.PassPercentage, testPrev.Successes+testPrev.Failures)
+			s += fmt.Sprintf(template, testLink, bug, test.PassPercentage, test.Successes+test.Failures, flakesAndTimeouts(test), arrow, testPrev.PassPercentage, testPrev.Successes+testPrev.Failures, sparkline(trends.Tests[test.Name]))
 		} else {
-			s += fmt.Sprintf(naTemplate, testLink, bug, test.PassPercentage, test.Successes+test.Failures)
+			s += fmt.Sprintf(naTemplate, testLink, bug, test.PassPercentage, test.Successes+test.Failures, flakesAndTimeouts(test), sparkline(trends.Tests[test.Name]))
+		}
+	}
+
+	s = s + "</table>"
+	return s
+}
+
+// knownIssues lists the known issues with the number of failing tests that match each, and flags the issues whose
+// bug was closed or that expired, so they can be updated or removed.
+func knownIssues(statuses []knownissues.Status) string {
+	s := `
+	<table class="table">
+		<tr>
+			<th colspan=4 class="text-center"><a class="text-dark" title="Tests whose failures are tracked by a bug until the expiry date.  Issues whose bug was closed or that expired should be updated or removed." id="KnownIssues" href="#KnownIssues">Known Issues</a></th>
+		</tr>
+		<tr>
+			<th>Pattern</th><th>Bug</th><th>Expires</th><th>Failing Tests</th>
+		</tr>
+	`
+
+	template := `
+		<tr>
+			<td>%s%s</td><td><a target="_blank" href="%s">%s</a> %s</td><td>%s %s</td><td>%d</td>
+		</tr>
+	`
+	for _, status := range statuses {
+		description := ""
+		if len(status.Description) > 0 {
+			description = fmt.Sprintf(`<br><span class="small text-muted">%s</span>`, gohtml.EscapeString(status.Description))
 		}
+		bugStatus := ""
+		if status.BugClosed {
+			bugStatus = `<span class="badge badge-danger">bug closed</span>`
+		} else if len(status.BugStatus) > 0 {
+			bugStatus = fmt.Sprintf(`<span class="small text-muted">%s</span>`, gohtml.EscapeString(status.BugStatus))
+		}
+		expired := ""
+		if status.Expired {
+			expired = `<span class="badge badge-danger">expired</span>`
+		}
+		s += fmt.Sprintf(template, gohtml.EscapeString(status.Pattern), description, status.BugURL(), status.BugID(), bugStatus,
+			gohtml.EscapeString(status.Expires), expired, len(status.Tests))
+	}
+	if len(statuses) == 0 {
+		s += `<tr><td colspan=4>No known issues</td></tr>`
 	}
+	s = s + "</table>"
+	return s
+}
+
+func summaryTopFlakyTests(flakes []*util.TestResult, resultPrev map[string]util.SortedAggregateTestResult, periods periods) string {
+	allPrev := resultPrev["all"]
+
+	s := fmt.Sprintf(`
+	<table class="table">
+		<tr>
+			<th colspan=4 class="text-center"><a class="text-dark" title="Tests that most often flaked (passed on a retry) or failed and then passed on the next run of the same job, sorted by flake rate.  These tests are unreliable rather than broken." id="TopFlakyTests" href="#TopFlakyTests">Top Flaky Tests</a></th>
+		</tr>
+		<tr>
+			<th/><th colspan=2 class="text-center">%s</th><th class="text-center">%s</th>
+		</tr>
+		<tr>
+			<th>Test Name</th><th>Flake Rate</th><th>Pass Rate</th><th>Flake Rate</th>
+		</tr>
+	`, periods.Current, periods.Prev)
+
+	template := `
+		<tr>
+			<td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d flakes, %d failed then passed)</span></td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span></td><td>%s</td>
+		</tr>
+	`
+
+	for _, test := range flakes {
+		encodedTestName := url.QueryEscape(regexp.QuoteMeta(test.Name))
+		testLink := fmt.Sprintf("<a target=\"_blank\" href=\"https://search.svc.ci.openshift.org/?maxAge=168h&context=1&type=bug%%2Bjunit&name=&maxMatches=5&maxBytes=20971520&groupBy=job&search=%s\">%s</a>", encodedTestName, test.Name)
 
+		// the previous top flaky tests are only a few of them, so look the test up in all of the previous results
+		prev := "NA"
+		if testPrev := getPrevTest(test.Name, allPrev.TestResults); testPrev != nil {
+			prev = fmt.Sprintf("%0.2f%%", testPrev.FlakePercentage)
+		}
+		s += fmt.Sprintf(template, testLink, test.FlakePercentage, test.Flakes, test.Flips, test.PassPercentage, test.Successes+test.Failures, prev)
+	}
 	s = s + "</table>"
 	return s
 }
@@ -433,19 +561,19 @@ func getPrevJob(job string, jobRunsByJob []util.JobResult) *util.JobResult {
 	return nil
 }
 
-func summaryJobPassRatesByJobName(report, reportPrev util.TestReport, endDay, jobTestCount int) string {
+func summaryJobPassRatesByJobName(report, reportPrev util.TestReport, periods periods, trends util.Trends, rules *util.Rules, jobTestCount int) string {
 	jobRunsByName := util.SummarizeJobsByName(report)
 	jobRunsByNamePrev := util.SummarizeJobsByName(reportPrev)
 
 	s := fmt.Sprintf(`
 	<table class="table">
 		<tr>
-			<th colspan=4 class="text-center"><a class="text-dark" title="Passing rate for each job definition, sorted by passing percentage.  Jobs at the top of this list are unreliable or represent environments where the product is not stable and should be investigated." id="JobPassRatesByJobName" href="#JobPassRatesByJobName">Job Pass Rates By Job Name</a></th>
+			<th colspan=5 class="text-center"><a class="text-dark" title="Passing rate for each job definition, sorted by passing percentage.  Jobs at the top of this list are unreliable or represent environments where the product is not stable and should be investigated." id="JobPassRatesByJobName" href="#JobPassRatesByJobName">Job Pass Rates By Job Name</a></th>
 		</tr>
 		<tr>
-			<th>Name</th><th>Latest %d days</th><th/><th>Previous 7 days</th>
+			<th>Name</th><th>%s</th><th/><th>%s</th><th>Trend</th>
 		</tr>
-	`, endDay)
+	`, periods.Current, periods.Prev)
 
 	template := `
 			<tr>
@@ -453,6 +581,7 @@ func summaryJobPassRatesByJobName(report, reportPrev util.TestReport, endDay, jo
 					<a target="_blank" href="%s">%s</a>
 					<p>
 					<button class="btn btn-primary btn-sm py-0" style="font-size: 0.8em" type="button" data-toggle="collapse" data-target=".%[3]s" aria-expanded="false" aria-controls="%[3]s">Expand Failing Tests</button>
+					<a class="small" style="padding-left: 10px" href="/job?release=%[4]s&name=%[5]s">Run History</a>
 				</td>
 				<td>
 					%0.2f%% <span class="text-nowrap">(%d runs)</span>
@@ -463,6 +592,9 @@ func summaryJobPassRatesByJobName(report, reportPrev util.TestReport, endDay, jo
 				<td>
 					%0.2f%% <span class="text-nowrap">(%d runs)</span>
 				</td>
+				<td>
+					%s
+				</td>
 			</tr>
 		`
 
@@ -472,6 +604,7 @@ func summaryJobPassRatesByJobName(report, reportPrev util.TestReport, endDay, jo
 					<a target="_blank" href="%s">%s</a>
 					<p>
 					<button class="btn btn-primary btn-sm py-0" style="font-size: 0.8em" type="button" data-toggle="collapse" data-target=".%[3]s" aria-expanded="false" aria-controls="%[3]s">Expand Failing Tests</button>
+					<a class="small" style="padding-left: 10px" href="/job?release=%[4]s&name=%[5]s">Run History</a>
 				</td>
 				<td>
 					%0.2f%% <span class="text-nowrap">(%d runs)</span>
@@ -480,6 +613,9 @@ func summaryJobPassRatesByJobName(report, reportPrev util.TestReport, endDay, jo
 				<td>
 					NA
 				</td>
+				<td>
+					%s
+				</td>
 			</tr>
 		`
 
@@ -488,33 +624,21 @@ func summaryJobPassRatesByJobName(report, reportPrev util.TestReport, endDay, jo
 		p := util.Percent(v.Successes, v.Failures)
 		if prev != nil {
 			pprev := util.Percent(prev.Successes, prev.Failures)
-			arrow := ""
-			delta := 5.0
-			if v.Successes+v.Failures > 80 {
-				delta = 2
-			}
-
-			if v.PassPercentage > prev.PassPercentage+delta {
-				arrow = fmt.Sprintf(up, v.PassPercentage-prev.PassPercentage)
-			} else if v.PassPercentage < prev.PassPercentage-delta {
-				arrow = fmt.Sprintf(down, prev.PassPercentage-v.PassPercentage)
-			} else if v.PassPercentage > prev.PassPercentage {
-				arrow = fmt.Sprintf(flatup, v.PassPercentage-prev.PassPercentage)
-			} else {
-				arrow = fmt.Sprintf(flatdown, prev.PassPercentage-v.PassPercentage)
-			}
+			arrow := passRateArrow(v.Successes, v.Failures, prev.Successes, prev.Failures)
 
-			s = s + fmt.Sprintf(template, v.TestGridUrl, v.Name, strings.ReplaceAll(v.Name, ".", ""),
+			s = s + fmt.Sprintf(template, v.TestGridUrl, v.Name, strings.ReplaceAll(v.Name, ".", ""), url.QueryEscape(report.Release), url.QueryEscape(v.Name),
 				p,
 				v.Successes+v.Failures,
 				arrow,
 				pprev,
 				prev.Successes+prev.Failures,
+				sparkline(trends.Jobs[v.Name]),
 			)
 		} else {
-			s = s + fmt.Sprintf(naTemplate, v.TestGridUrl, v.Name, strings.ReplaceAll(v.Name, ".", ""),
+			s = s + fmt.Sprintf(naTemplate, v.TestGridUrl, v.Name, strings.ReplaceAll(v.Name, ".", ""), url.QueryEscape(report.Release), url.QueryEscape(v.Name),
 				p,
 				v.Successes+v.Failures,
+				sparkline(trends.Jobs[v.Name]),
 			)
 		}
 
@@ -524,7 +648,7 @@ func summaryJobPassRatesByJobName(report, reportPrev util.TestReport, endDay, jo
 		rows := ""
 		additionalMatches := 0
 		for _, test := range jobTests.TestResults {
-			if util.IgnoreTestRegex.MatchString(test.Name) {
+			if rules.IgnoreTest(test.Name) {
 				continue
 			}
 			if count == 0 {
@@ -576,7 +700,7 @@ func canaryTestFailures(result map[string]util.SortedAggregateTestResult) string
 		</tr>
 	`
 
-	for i := len(all) - 1; i > len(all)-10; i-- {
+	for i := len(all) - 1; i >= 0 && i > len(all)-10; i-- {
 		test := all[i]
 		encodedTestName := url.QueryEscape(regexp.QuoteMeta(test.Name))
 
@@ -600,23 +724,74 @@ func failureGroupList(report util.TestReport) string {
 
 	template := `
 	<tr>
-		<td><a target="_blank" href=%s>%s</a></td><td>%d</td>
+		<td><a target="_blank" href=%s>%s</a></td><td><a href="/run?url=%s">%d</a></td>
 	</tr>`
 	for _, fg := range report.FailureGroups {
-		s += fmt.Sprintf(template, fg.Url, fg.Job, fg.TestFailures)
+		s += fmt.Sprintf(template, fg.Url, fg.Job, url.QueryEscape(fg.Url), fg.TestFailures)
 	}
 	s = s + "</table>"
 	return s
 }
 
 type TestReports struct {
-	Current      util.TestReport
-	Prev         util.TestReport
-	EndDay       int
+	Current util.TestReport
+	Prev    util.TestReport
+	Trends  util.Trends
+	// Rules are the rules the reports were analyzed with, they decide the variant dimensions and the ignored tests.
+	Rules        *util.Rules
 	JobTestCount int
+	Settings     []Setting
+}
+
+// Setting is one of the options a report was generated with, they are listed at the top of the page.
+type Setting struct {
+	Name  string
+	Value string
+}
+
+func settingsHtml(settings []Setting) string {
+	if len(settings) == 0 {
+		return ""
+	}
+	s := `<p class="small text-muted mb-3">Report options: `
+	for i, setting := range settings {
+		if i > 0 {
+			s += " | "
+		}
+		s += fmt.Sprintf("%s: <code>%s</code>", gohtml.EscapeString(setting.Name), gohtml.EscapeString(setting.Value))
+	}
+	return s + "</p>"
+}
+
+// periods are the column headings for the current and previous reports.
+type periods struct {
+	Current string
+	Prev    string
+}
+
+func (r TestReports) Periods() periods {
+	return periods{
+		Current: period(r.Current),
+		Prev:    previousPeriod(r.Current, r.Prev),
+	}
+}
+
+// period describes the days covered by a report, e.g. "Latest 7 Days" or "Days 14-21 Ago".
+func period(report util.TestReport) string {
+	if report.StartDay == 0 {
+		return fmt.Sprintf("Latest %d Days", report.EndDay)
+	}
+	return fmt.Sprintf("Days %d-%d Ago", report.StartDay, report.EndDay)
+}
+
+func previousPeriod(report, prev util.TestReport) string {
+	if prev.StartDay == report.EndDay {
+		return fmt.Sprintf("Previous %d Days", prev.EndDay-prev.StartDay)
+	}
+	return period(prev)
 }
 
-func PrintHtmlReport(w http.ResponseWriter, req *http.Request, report, prevReport util.TestReport, endDay, jobTestCount int) {
+func PrintHtmlReport(w http.ResponseWriter, req *http.Request, report, prevReport util.TestReport, trends util.Trends, rules *util.Rules, jobTestCount int, settings []Setting) {
 
 	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
 	fmt.Fprintf(w, htmlPageStart, "Release CI Health Dashboard")
@@ -625,15 +800,18 @@ func PrintHtmlReport(w http.ResponseWriter, req *http.Request, report, prevRepor
 		template.FuncMap{
 			"summaryAcrossAllJobs":         summaryAcrossAllJobs,
 			"failureGroups":                failureGroups,
-			"summaryJobsByPlatform":        summaryJobsByPlatform,
+			"summaryJobsByVariant":         summaryJobsByVariant,
 			"summaryTopFailingTests":       summaryTopFailingTests,
+			"summaryTopFlakyTests":         summaryTopFlakyTests,
+			"knownIssues":                  knownIssues,
 			"summaryJobPassRatesByJobName": summaryJobPassRatesByJobName,
 			"canaryTestFailures":           canaryTestFailures,
 			"failureGroupList":             failureGroupList,
+			"settings":                     settingsHtml,
 		},
 	).Parse(dashboardPageHtml))
 
-	if err := dashboardPage.Execute(w, TestReports{report, prevReport, endDay, jobTestCount}); err != nil {
+	if err := dashboardPage.Execute(w, TestReports{report, prevReport, trends, rules, jobTestCount, settings}); err != nil {
 		klog.Errorf("Unable to render page: %v", err)
 	}
 
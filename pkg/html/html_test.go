@@ -0,0 +1,71 @@
+package html
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bparees/sippy/pkg/util"
+)
+
+func TestFailureGroups(t *testing.T) {
+	groups := func(sizes ...int) []util.JobRunResult {
+		runs := []util.JobRunResult{}
+		for _, size := range sizes {
+			runs = append(runs, util.JobRunResult{TestFailures: size})
+		}
+		return runs
+	}
+	tests := []struct {
+		name          string
+		current, prev []util.JobRunResult
+		rows          []string
+	}{
+		{
+			name:    "both periods",
+			current: groups(10, 20, 60),
+			prev:    groups(100, 200),
+			rows: []string{
+				"<td>Job Runs with a Failure Group: </td><td>3</td><td>2</td>",
+				"<td>Average Failure Group Size: </td><td>30</td><td>150</td>",
+				"<td>Median Failure Group Size: </td><td>20</td><td>200</td>",
+			},
+		},
+		{
+			name:    "no previous groups",
+			current: groups(12),
+			rows: []string{
+				"<td>Job Runs with a Failure Group: </td><td>1</td><td>0</td>",
+				"<td>Average Failure Group Size: </td><td>12</td><td>0</td>",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := failureGroups(tt.current, tt.prev, periods{Current: "Last 7 Days", Prev: "Previous 7 Days"})
+			for _, row := range tt.rows {
+				if !strings.Contains(s, row) {
+					t.Errorf("expected the row %s in\n%s", row, s)
+				}
+			}
+		})
+	}
+}
+
+func TestCanaryTestFailures(t *testing.T) {
+	for _, count := range []int{0, 1, 5, 9, 20} {
+		results := []util.TestResult{}
+		for i := 0; i < count; i++ {
+			results = append(results, util.TestResult{Name: fmt.Sprintf("test %02d", i), Successes: 99, Failures: 1})
+		}
+		s := canaryTestFailures(map[string]util.SortedAggregateTestResult{"all": {TestResults: results}})
+		// the tests with the highest pass rates are listed, up to 9 of them
+		expected := count
+		if expected > 9 {
+			expected = 9
+		}
+		if rows := strings.Count(s, "(100 runs)"); rows != expected {
+			t.Errorf("expected %d canary tests out of %d, got %d", expected, count, rows)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package html
+
+import (
+	"fmt"
+	gohtml "html"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/bparees/sippy/pkg/util"
+)
+
+const (
+	jobPageHtml = `
+<h1 class=text-center>{{ escape .Summary.Name }}</h1>
+
+<p class="text-center mb-3">
+	{{ jobResult .Summary }} {{ sparkline .Trend }} | <a target="_blank" href="{{ escape .Summary.TestGridUrl }}">Testgrid</a>
+</p>
+
+<p class="small mb-3">
+	Jump to: <a href="#JobRuns">Runs</a> | <a href="#JobWorstTests">Worst Tests</a> | <a href="#JobRunList">Run History</a>
+</p>
+
+{{ jobRunStrip .Summary }}
+
+{{ jobWorstTests .Summary }}
+
+{{ jobRunList .Summary }}
+`
+)
+
+type jobPage struct {
+	Summary util.JobSummary
+	Trend   util.Trend
+}
+
+// jobResult describes the pass rate of the job compared to the previous period.
+func jobResult(summary util.JobSummary) string {
+	s := fmt.Sprintf("%s: %0.2f%% <span class=\"text-nowrap\">(%d runs)</span> in %s", gohtml.EscapeString(summary.Variant.String()),
+		summary.PassRate.PassPercentage, summary.PassRate.Runs(), gohtml.EscapeString(summary.Release))
+	if len(summary.DashboardType) > 0 {
+		s += fmt.Sprintf(" <span class=\"badge badge-secondary\">%s</span>", gohtml.EscapeString(summary.DashboardType))
+	}
+	if summary.PrevPassRate == nil {
+		return s + ", no runs in the previous period"
+	}
+	prev := summary.PrevPassRate
+	return s + fmt.Sprintf(" %s previously %0.2f%% <span class=\"text-nowrap\">(%d runs)</span>",
+		passRateArrow(summary.PassRate.Successes, summary.PassRate.Failures, prev.Successes, prev.Failures), prev.PassPercentage, prev.Runs())
+}
+
+// runLink links to the page for the job run.
+func runLink(run util.JobRun, text string) string {
+	return fmt.Sprintf("<a href=\"/run?url=%s\">%s</a>", url.QueryEscape(run.Url), text)
+}
+
+// runStatus is the color and description of the outcome of a job run.
+func runStatus(run util.JobRun) (string, string) {
+	switch {
+	case run.Failed:
+		return "red", "failed"
+	case run.Succeeded:
+		return "green", "passed"
+	}
+	return "darkgray", "unknown"
+}
+
+// jobRunStrip shows a block for each run of the job, newest on the left, linking to the run page.
+func jobRunStrip(summary util.JobSummary) string {
+	s := `
+	<table class="table">
+		<tr>
+			<th class="text-center"><a class="text-dark" title="Each run of the job, newest first.  Green runs passed, red runs failed, outlined runs are failure groups.  Hover for the time of the run, click to see the tests that failed in it." id="JobRuns" href="#JobRuns">Runs</a></th>
+		</tr>
+		<tr>
+			<td>`
+
+	template := `<a href="/run?url=%s" title="%s"><span class="d-inline-block" style="width:8px;height:20px;margin:1px;background-color:%s;%s"></span></a>`
+	for _, run := range summary.Runs {
+		color, result := runStatus(run)
+		title := fmt.Sprintf("%s at %s, %d tests failed", result, run.Timestamp.Format("Jan 2 15:04 2006 MST"), run.TestFailures)
+		border := ""
+		if run.FailureGroup {
+			border = "outline:2px solid black"
+		}
+		s += fmt.Sprintf(template, url.QueryEscape(run.Url), gohtml.EscapeString(title), color, border)
+	}
+	if len(summary.Runs) == 0 {
+		s += "No runs"
+	}
+	s += `</td>
+		</tr>
+	</table>`
+	return s
+}
+
+func jobWorstTests(summary util.JobSummary) string {
+	s := `
+	<table class="table">
+		<tr>
+			<th colspan=2 class="text-center"><a class="text-dark" title="Tests with the lowest pass rates in this job." id="JobWorstTests" href="#JobWorstTests">Worst Tests</a></th>
+		</tr>
+		<tr>
+			<th>Test Name</th><th>Pass Rate</th>
+		</tr>
+	`
+
+	template := `
+		<tr>
+			<td><a href="/test?release=%s&name=%s">%s</a></td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span>%s</td>
+		</tr>
+	`
+	for _, test := range summary.WorstTests {
+		s += fmt.Sprintf(template, url.QueryEscape(summary.Release), url.QueryEscape(test.Name), gohtml.EscapeString(test.Name),
+			test.PassPercentage, test.Successes+test.Failures, flakesAndTimeouts(&test))
+	}
+	if len(summary.WorstTests) == 0 {
+		s += `<tr><td colspan=2>No failing tests</td></tr>`
+	}
+	s = s + "</table>"
+	return s
+}
+
+// jobRunList lists each run of the job with the number of tests that failed in it.
+func jobRunList(summary util.JobSummary) string {
+	s := fmt.Sprintf(`
+	<table class="table">
+		<tr>
+			<th colspan=4 class="text-center"><a class="text-dark" title="Each run of the job, newest first.  Failure groups are runs where a large number of tests failed, %d of the runs are failure groups." id="JobRunList" href="#JobRunList">Run History</a></th>
+		</tr>
+		<tr>
+			<th>Time</th><th>Result</th><th>Failed Test Count</th><th>Prow</th>
+		</tr>
+	`, summary.FailureGroups)
+
+	template := `
+		<tr>
+			<td>%s</td><td>%s</td><td>%s</td><td><a target="_blank" href="%s">Prow</a></td>
+		</tr>
+	`
+	for _, run := range summary.Runs {
+		_, result := runStatus(run)
+		if run.FailureGroup {
+			result += ` <span class="badge badge-danger">failure group</span>`
+		}
+		s += fmt.Sprintf(template, run.Timestamp.Format("Jan 2 15:04 2006 MST"), result, runLink(run, fmt.Sprintf("%d", run.TestFailures)), gohtml.EscapeString(run.Url))
+	}
+	s = s + "</table>"
+	return s
+}
+
+// PrintJobReport renders the run history of a single job, from the data as of timestamp.
+func PrintJobReport(w http.ResponseWriter, req *http.Request, summary util.JobSummary, trend util.Trend, timestamp time.Time) {
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	fmt.Fprintf(w, htmlPageStart, "Job Details")
+
+	var page = template.Must(template.New("jobPage").Funcs(
+		template.FuncMap{
+			"escape":        gohtml.EscapeString,
+			"sparkline":     sparkline,
+			"jobResult":     jobResult,
+			"jobRunStrip":   jobRunStrip,
+			"jobWorstTests": jobWorstTests,
+			"jobRunList":    jobRunList,
+		},
+	).Parse(jobPageHtml))
+
+	if err := page.Execute(w, jobPage{summary, trend}); err != nil {
+		klog.Errorf("Unable to render page: %v", err)
+	}
+
+	fmt.Fprintf(w, htmlPageEnd, timestamp.Format("Jan 2 15:04 2006 MST"))
+}
@@ -0,0 +1,96 @@
+package html
+
+import (
+	"fmt"
+	gohtml "html"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"k8s.io/klog"
+
+	"github.com/bparees/sippy/pkg/util"
+)
+
+const (
+	releasesPageHtml = `
+<h1 class=text-center>{{ releaseNames .Releases }}</h1>
+
+<p class="small mb-3">
+	Jump to: <a href="#ReleaseVariants">Job Pass Rates By Variant</a> | <a href="#ReleaseJobs">Job Pass Rates By Job Name</a> |
+	         <a href="#ReleaseTests">Test Pass Rates</a>
+</p>
+
+{{ releaseTable "ReleaseVariants" "Job Pass Rates By Variant" "Aggregation of all job runs for each value of each variant dimension, such as cloud=aws, in each release, largest regressions in the newest release first." .Variants .Releases .Count }}
+
+{{ releaseTable "ReleaseJobs" "Job Pass Rates By Job Name" "Pass rate of each job, matched across releases by its name without the release, largest regressions in the newest release first." .Jobs .Releases .Count }}
+
+{{ releaseTable "ReleaseTests" "Test Pass Rates" "Pass rate of each test in each release, largest regressions in the newest release first." .Tests .Releases .Count }}
+`
+)
+
+type releasesPage struct {
+	util.ReleaseComparison
+	Count int
+}
+
+func releaseNames(releases []string) string {
+	return gohtml.EscapeString(strings.Join(releases, " vs "))
+}
+
+// releaseTable lists up to count rows, which are already sorted with the largest regressions first.  The arrow
+// marks the change in the newest release.
+func releaseTable(id, title, description string, rows []util.ReleaseRow, releases []string, count int) string {
+	s := fmt.Sprintf(`
+	<table class="table">
+		<tr>
+			<th colspan=%[4]d class="text-center"><a class="text-dark" title="%[3]s" id="%[1]s" href="#%[1]s">%[2]s</a></th>
+		</tr>
+		<tr>
+			<th>Name</th>`, id, title, description, len(releases)+2)
+	for _, release := range releases {
+		s += fmt.Sprintf("<th>%s</th>", gohtml.EscapeString(release))
+	}
+	s += "<th/></tr>"
+
+	for i, row := range rows {
+		if i == count {
+			s += fmt.Sprintf(`<tr><td colspan=%d>Plus %d more</td></tr>`, len(releases)+2, len(rows)-count)
+			break
+		}
+		s += fmt.Sprintf("<tr><td>%s</td>", gohtml.EscapeString(row.Name))
+		for _, p := range row.PassRates {
+			if p == nil {
+				s += "<td>NA</td>"
+				continue
+			}
+			s += fmt.Sprintf(`<td>%0.2f%% <span class="text-nowrap">(%d runs)</span></td>`, p.PassPercentage, p.Runs())
+		}
+		arrow := ""
+		if n := len(row.PassRates); row.Delta != nil {
+			arrow = passRateArrow(row.PassRates[n-1].Successes, row.PassRates[n-1].Failures, row.PassRates[n-2].Successes, row.PassRates[n-2].Failures)
+		}
+		s += fmt.Sprintf("<td>%s</td></tr>", arrow)
+	}
+	s = s + "</table>"
+	return s
+}
+
+// PrintReleasesReport renders the comparison of several releases, showing at most count tests and jobs.
+func PrintReleasesReport(w http.ResponseWriter, req *http.Request, comparison util.ReleaseComparison, count int) {
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	fmt.Fprintf(w, htmlPageStart, "Release Comparison")
+
+	var page = template.Must(template.New("releasesPage").Funcs(
+		template.FuncMap{
+			"releaseNames": releaseNames,
+			"releaseTable": releaseTable,
+		},
+	).Parse(releasesPageHtml))
+
+	if err := page.Execute(w, releasesPage{comparison, count}); err != nil {
+		klog.Errorf("Unable to render page: %v", err)
+	}
+
+	fmt.Fprintf(w, htmlPageEnd, comparison.Timestamp.Format("Jan 2 15:04 2006 MST"))
+}
@@ -0,0 +1,90 @@
+package html
+
+import (
+	"fmt"
+	gohtml "html"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/bparees/sippy/pkg/util"
+)
+
+const (
+	runPageHtml = `
+<h1 class=text-center>{{ escape .Job }}</h1>
+
+<p class="text-center mb-3">
+	{{ runResult . }} | <a target="_blank" href="{{ escape .Url }}">Prow</a> | <a target="_blank" href="{{ escape .TestGridJobUrl }}">Testgrid</a>
+</p>
+
+{{ runFailedTests . }}
+`
+)
+
+// runResult describes the outcome of the run and how many of its failures were canary tests.
+func runResult(run util.JobRunSummary) string {
+	result := "Result unknown"
+	switch {
+	case run.Failed:
+		result = "Failed"
+	case run.Succeeded:
+		result = "Succeeded"
+	}
+	return fmt.Sprintf("%s (release %s) with %d of %d tests failing, %d of them canary tests", result,
+		gohtml.EscapeString(run.Release), len(run.FailedTests), run.TestCount, run.CanaryFailures)
+}
+
+func runFailedTests(run util.JobRunSummary) string {
+	s := fmt.Sprintf(`
+	<table class="table">
+		<tr>
+			<th colspan=3 class="text-center"><a class="text-dark" title="Tests that failed in this run with their pass rate across all jobs.  Canary tests pass more than %0.0f%% of the time, when many of them failed the run was probably disrupted by a cluster or infrastructure problem rather than the tests." id="FailedTests" href="#FailedTests">Failed Tests</a></th>
+		</tr>
+		<tr>
+			<th>Test Name</th><th/><th>Pass Rate</th>
+		</tr>
+	`, util.CanaryPassPercentage)
+
+	template := `
+		<tr>
+			<td>%s</td><td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d runs)</span></td>
+		</tr>
+	`
+	for _, test := range run.FailedTests {
+		testLink := fmt.Sprintf("<a href=\"/test?release=%s&name=%s\">%s</a>", url.QueryEscape(run.Release), url.QueryEscape(test.Name), gohtml.EscapeString(test.Name))
+		canary := ""
+		if test.Canary {
+			canary = `<span class="badge badge-warning">canary</span>`
+		}
+		s += fmt.Sprintf(template, testLink, canary, test.PassPercentage, test.Runs())
+	}
+	if len(run.FailedTests) == 0 {
+		s += `<tr><td colspan=3>No tests failed</td></tr>`
+	}
+	s = s + "</table>"
+	return s
+}
+
+// PrintRunReport renders the tests that failed in a single job run, from the data as of timestamp.
+func PrintRunReport(w http.ResponseWriter, req *http.Request, run util.JobRunSummary, timestamp time.Time) {
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	fmt.Fprintf(w, htmlPageStart, "Job Run")
+
+	var page = template.Must(template.New("runPage").Funcs(
+		template.FuncMap{
+			"escape":         gohtml.EscapeString,
+			"runResult":      runResult,
+			"runFailedTests": runFailedTests,
+		},
+	).Parse(runPageHtml))
+
+	if err := page.Execute(w, run); err != nil {
+		klog.Errorf("Unable to render page: %v", err)
+	}
+
+	fmt.Fprintf(w, htmlPageEnd, timestamp.Format("Jan 2 15:04 2006 MST"))
+}
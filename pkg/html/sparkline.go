@@ -0,0 +1,49 @@
+package html
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bparees/sippy/pkg/util"
+)
+
+const (
+	sparklineWidth  = 100
+	sparklineHeight = 24
+)
+
+// sparkline renders a pass rate trend as a small inline svg line, oldest point on the left.  Buckets with no runs
+// are left out of the line, and hovering shows the pass rate of each bucket.
+func sparkline(trend util.Trend) string {
+	if len(trend) == 0 {
+		return ""
+	}
+
+	step := float64(sparklineWidth)
+	if len(trend) > 1 {
+		step = float64(sparklineWidth) / float64(len(trend)-1)
+	}
+	points := []string{}
+	titles := []string{}
+	for i, p := range trend {
+		if p.Successes+p.Failures == 0 {
+			titles = append(titles, fmt.Sprintf("Days %d-%d ago: no runs", p.StartDay, p.EndDay))
+			continue
+		}
+		titles = append(titles, fmt.Sprintf("Days %d-%d ago: %0.2f%% (%d runs)", p.StartDay, p.EndDay, p.PassPercentage, p.Successes+p.Failures))
+		// 100% is drawn at the top, leaving a pixel of margin so the line is not clipped
+		y := 1 + (100-p.PassPercentage)*float64(sparklineHeight-2)/100
+		points = append(points, fmt.Sprintf("%0.1f,%0.1f", float64(i)*step, y))
+	}
+	if len(points) == 0 {
+		return ""
+	}
+	if len(points) == 1 {
+		// a single point is drawn as a flat line so it is visible
+		y := strings.Split(points[0], ",")[1]
+		points = []string{"0," + y, fmt.Sprintf("%d,%s", sparklineWidth, y)}
+	}
+
+	return fmt.Sprintf(`<svg width="%[1]d" height="%[2]d" viewBox="0 0 %[1]d %[2]d"><title>%[3]s</title><polyline fill="none" stroke="steelblue" stroke-width="1.5" points="%[4]s"/></svg>`,
+		sparklineWidth, sparklineHeight, strings.Join(titles, "\n"), strings.Join(points, " "))
+}
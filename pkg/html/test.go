@@ -0,0 +1,141 @@
+package html
+
+import (
+	"fmt"
+	gohtml "html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/bparees/sippy/pkg/util"
+)
+
+const (
+	testPageHtml = `
+<h1 class=text-center>{{ escape .Summary.Name }}</h1>
+
+<p class="text-center mb-3">
+	{{ testResult .Summary }} {{ sparkline .Trend }} | {{ testLinks .Summary }}
+</p>
+
+<p class="small mb-3">
+	Jump to: <a href="#TestRuns">Runs</a> | <a href="#TestReleases">Pass Rate By Release</a> |
+	         <a href="#TestJobs">Pass Rate By Job</a>{{ range .Summary.Variants }} | <a href="#TestVariant-{{ escape .Dimension }}">Pass Rate By {{ escape .Dimension }}</a>{{ end }}
+</p>
+
+{{ testRuns .Summary }}
+
+{{ testBreakdown "TestReleases" "Pass Rate By Release" "Pass rate of the test in each release being reported on." .Summary.Releases }}
+
+{{ testBreakdown "TestJobs" "Pass Rate By Job" "Pass rate of the test in each job that runs it, lowest first." .Summary.Jobs }}
+
+{{ range .Summary.Variants }}
+{{ testBreakdown (printf "TestVariant-%s" (escape .Dimension)) (printf "Pass Rate By %s" (escape .Dimension)) "Pass rate of the test across the jobs with each value of the variant dimension, lowest first." .Values }}
+{{ end }}
+`
+)
+
+type testPage struct {
+	Summary util.TestSummary
+	Trend   util.Trend
+}
+
+// testResult describes the owners and overall pass rate of the test and lists its bugs.
+func testResult(summary util.TestSummary) string {
+	owners := strings.Join([]string{summary.Sig, summary.Component, summary.Team}, ", ")
+	s := fmt.Sprintf("%s: %0.2f%% <span class=\"text-nowrap\">(%d runs)</span> in %s", gohtml.EscapeString(owners),
+		summary.PassRate.PassPercentage, summary.PassRate.Runs(), gohtml.EscapeString(summary.Release))
+	if len(summary.BugList) == 0 {
+		return s + ", no known bugs"
+	}
+	bugs := []string{}
+	for _, b := range summary.BugList {
+		bugID := strings.TrimPrefix(b, "https://bugzilla.redhat.com/show_bug.cgi?id=")
+		bugs = append(bugs, fmt.Sprintf("<a target=\"_blank\" href=\"%s\">%s</a>", gohtml.EscapeString(b), gohtml.EscapeString(bugID)))
+	}
+	return s + ", bugs: " + strings.Join(bugs, " ")
+}
+
+func testLinks(summary util.TestSummary) string {
+	encodedTestName := url.QueryEscape(regexp.QuoteMeta(summary.Name))
+	return fmt.Sprintf("<a target=\"_blank\" href=\"https://search.svc.ci.openshift.org/?maxAge=168h&context=1&type=bug%%2Bjunit&name=&maxMatches=5&maxBytes=20971520&groupBy=job&search=%s\">Search</a>", encodedTestName)
+}
+
+// testRuns shows a strip with a block for each run of the test, newest on the left, linking to the run in prow.
+func testRuns(summary util.TestSummary) string {
+	s := `
+	<table class="table">
+		<tr>
+			<th class="text-center"><a class="text-dark" title="Each run of the test, newest first.  Green runs passed, red runs failed.  Hover for the job and time of the run, click to open it in prow." id="TestRuns" href="#TestRuns">Runs</a></th>
+		</tr>
+		<tr>
+			<td>`
+
+	template := `<a target="_blank" href="%s" title="%s"><span class="d-inline-block" style="width:8px;height:20px;margin:1px;background-color:%s"></span></a>`
+	for _, run := range summary.Runs {
+		color, result := "green", "passed"
+		if run.Failed {
+			color, result = "red", "failed"
+		}
+		title := fmt.Sprintf("%s %s at %s", run.Job, result, run.Timestamp.Format("Jan 2 15:04 2006 MST"))
+		s += fmt.Sprintf(template, gohtml.EscapeString(run.Url), gohtml.EscapeString(title), color)
+	}
+	if len(summary.Runs) == 0 {
+		s += "No runs"
+	}
+	s += `</td>
+		</tr>
+	</table>`
+	return s
+}
+
+func testBreakdown(id, title, description string, breakdowns []util.TestBreakdown) string {
+	s := fmt.Sprintf(`
+	<table class="table">
+		<tr>
+			<th colspan=2 class="text-center"><a class="text-dark" title="%[3]s" id="%[1]s" href="#%[1]s">%[2]s</a></th>
+		</tr>
+		<tr>
+			<th>Name</th><th>Pass Rate</th>
+		</tr>
+	`, id, title, description)
+
+	template := `
+		<tr>
+			<td>%s</td><td>%0.2f%% <span class="text-nowrap">(%d runs, %d flakes)</span></td>
+		</tr>
+	`
+	for _, b := range breakdowns {
+		s += fmt.Sprintf(template, gohtml.EscapeString(b.Name), b.PassPercentage, b.Runs(), b.Flakes)
+	}
+	s = s + "</table>"
+	return s
+}
+
+// PrintTestReport renders the breakdown of a single test's results, from the data as of timestamp.
+func PrintTestReport(w http.ResponseWriter, req *http.Request, summary util.TestSummary, trend util.Trend, timestamp time.Time) {
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	fmt.Fprintf(w, htmlPageStart, "Test Details")
+
+	var page = template.Must(template.New("testPage").Funcs(
+		template.FuncMap{
+			"escape":        gohtml.EscapeString,
+			"sparkline":     sparkline,
+			"testResult":    testResult,
+			"testLinks":     testLinks,
+			"testRuns":      testRuns,
+			"testBreakdown": testBreakdown,
+		},
+	).Parse(testPageHtml))
+
+	if err := page.Execute(w, testPage{summary, trend}); err != nil {
+		klog.Errorf("Unable to render page: %v", err)
+	}
+
+	fmt.Fprintf(w, htmlPageEnd, timestamp.Format("Jan 2 15:04 2006 MST"))
+}
@@ -0,0 +1,49 @@
+package knownissues
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// BugzillaURL is the bugzilla REST api the status of the bugs of known issues is looked up in.
+const BugzillaURL = "https://bugzilla.redhat.com/rest/bug"
+
+// FindBugStatuses looks up the status of the bugs, e.g. NEW or CLOSED, keyed by bug id.  The lookup is given up
+// when ctx is done.
+func FindBugStatuses(ctx context.Context, ids []string) (map[string]string, error) {
+	statuses := make(map[string]string)
+	if len(ids) == 0 {
+		return statuses, nil
+	}
+	query := url.Values{"id": {strings.Join(ids, ",")}, "include_fields": {"id,status"}}
+	req, err := http.NewRequest(http.MethodGet, BugzillaURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return statuses, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return statuses, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statuses, fmt.Errorf("bugzilla returned %s", resp.Status)
+	}
+	result := struct {
+		Bugs []struct {
+			ID     int    `json:"id"`
+			Status string `json:"status"`
+		} `json:"bugs"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return statuses, err
+	}
+	for _, bug := range result.Bugs {
+		statuses[strconv.Itoa(bug.ID)] = bug.Status
+	}
+	return statuses, nil
+}
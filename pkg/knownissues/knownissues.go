@@ -0,0 +1,294 @@
+package knownissues
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DateFormat is the format of the expiry date of an issue.
+const DateFormat = "2006-01-02"
+
+// Issue links the failures of the tests whose names match Pattern to a bug that tracks them.  The failures are
+// reported separately from the untracked ones until the end of the Expires date, after which they are reported as
+// untracked again.
+type Issue struct {
+	ID          int    `json:"id" yaml:"id"`
+	Pattern     string `json:"pattern" yaml:"pattern"`
+	Bug         string `json:"bug" yaml:"bug"`
+	Expires     string `json:"expires" yaml:"expires"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+var bugIDRegex = regexp.MustCompile(`^(?:https://bugzilla\.redhat\.com/show_bug\.cgi\?id=)?(\d+)$`)
+
+// BugID is the bugzilla id of the bug of the issue.
+func (i Issue) BugID() string {
+	if match := bugIDRegex.FindStringSubmatch(i.Bug); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// BugURL links to the bug of the issue.
+func (i Issue) BugURL() string {
+	return "https://bugzilla.redhat.com/show_bug.cgi?id=" + i.BugID()
+}
+
+// Expired returns true once the expiry date of the issue has passed.
+func (i Issue) Expired(now time.Time) bool {
+	expires, err := time.Parse(DateFormat, i.Expires)
+	if err != nil {
+		return true
+	}
+	return !now.UTC().Before(expires.AddDate(0, 0, 1))
+}
+
+func (i Issue) validate() (*regexp.Regexp, error) {
+	if len(i.Pattern) == 0 {
+		return nil, fmt.Errorf("known issue %d has no pattern", i.ID)
+	}
+	regex, err := regexp.Compile(i.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for known issue %d: %v", i.ID, err)
+	}
+	if len(i.BugID()) == 0 {
+		return nil, fmt.Errorf("invalid bug %q for known issue %d, must be a bugzilla id or url", i.Bug, i.ID)
+	}
+	if _, err := time.Parse(DateFormat, i.Expires); err != nil {
+		return nil, fmt.Errorf("invalid expiry %q for known issue %d, must be a date such as 2020-12-31", i.Expires, i.ID)
+	}
+	return regex, nil
+}
+
+// Status is a known issue as of a report: the failing tests that match it, and whether it needs attention because
+// it expired or its bug was closed or fixed (ON_QA, VERIFIED or RELEASE_PENDING).  BugStatus is empty if the status
+// of the bug could not be looked up.
+type Status struct {
+	Issue
+	Tests     []string `json:"tests"`
+	BugStatus string   `json:"bugStatus,omitempty"`
+	BugClosed bool     `json:"bugClosed"`
+	Expired   bool     `json:"expired"`
+}
+
+// Registry is the list of known issues, kept in a YAML file.  Changes made through the registry are written back
+// to the file.  A registry without a file is empty and can't be changed.  It is safe for concurrent use.
+type Registry struct {
+	path    string
+	lock    sync.RWMutex
+	issues  []Issue
+	regexes []*regexp.Regexp
+}
+
+// Load reads the known issues from the file at path.  A file that doesn't exist yet is an empty registry, it is
+// created by the first change.
+func Load(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the file again, to pick up changes made to it by hand.  The registry is left unchanged if the file
+// is invalid.
+func (r *Registry) Reload() error {
+	if len(r.path) == 0 {
+		return nil
+	}
+	issues := []Issue{}
+	b, err := ioutil.ReadFile(r.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read known issues %s: %v", r.path, err)
+	}
+	if err := yaml.UnmarshalStrict(b, &issues); err != nil {
+		return fmt.Errorf("unable to parse known issues %s: %v", r.path, err)
+	}
+	regexes, err := compile(issues)
+	if err != nil {
+		return fmt.Errorf("invalid known issues %s: %v", r.path, err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.issues, r.regexes = issues, regexes
+	return nil
+}
+
+func compile(issues []Issue) ([]*regexp.Regexp, error) {
+	regexes := []*regexp.Regexp{}
+	ids := make(map[int]bool)
+	for _, issue := range issues {
+		if ids[issue.ID] {
+			return nil, fmt.Errorf("duplicate known issue id %d", issue.ID)
+		}
+		ids[issue.ID] = true
+		regex, err := issue.validate()
+		if err != nil {
+			return nil, err
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+// Path is the file the known issues are kept in, empty if the registry has no file.
+func (r *Registry) Path() string {
+	return r.path
+}
+
+// Issues lists the known issues by id.
+func (r *Registry) Issues() []Issue {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	issues := make([]Issue, len(r.issues))
+	copy(issues, r.issues)
+	return issues
+}
+
+// Get returns the known issue with the id.
+func (r *Registry) Get(id int) (Issue, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, issue := range r.issues {
+		if issue.ID == id {
+			return issue, true
+		}
+	}
+	return Issue{}, false
+}
+
+// Match returns the first known issue that hasn't expired whose pattern matches the test name.
+func (r *Registry) Match(name string, now time.Time) (Issue, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for i, issue := range r.issues {
+		if !issue.Expired(now) && r.regexes[i].MatchString(name) {
+			return issue, true
+		}
+	}
+	return Issue{}, false
+}
+
+// Statuses checks every known issue against the failing tests of a report and the status of the bugs, keyed by
+// bug id.
+func (r *Registry) Statuses(failingTests []string, bugStatuses map[string]string, now time.Time) []Status {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	statuses := []Status{}
+	for i, issue := range r.issues {
+		status := Status{
+			Issue:     issue,
+			Tests:     []string{},
+			BugStatus: bugStatuses[issue.BugID()],
+			Expired:   issue.Expired(now),
+		}
+		status.BugClosed = bugClosed(status.BugStatus)
+		for _, name := range failingTests {
+			if r.regexes[i].MatchString(name) {
+				status.Tests = append(status.Tests, name)
+			}
+		}
+		sort.Strings(status.Tests)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// bugClosed reports whether the bugzilla status means the bug no longer needs work, either because it was closed or
+// because a fix was made and is waiting to ship or be verified.
+func bugClosed(status string) bool {
+	switch status {
+	case "CLOSED", "VERIFIED", "RELEASE_PENDING", "ON_QA":
+		return true
+	}
+	return false
+}
+
+// BugIDs are the ids of the bugs of the known issues.
+func (r *Registry) BugIDs() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	ids := []string{}
+	for _, issue := range r.issues {
+		ids = append(ids, issue.BugID())
+	}
+	return ids
+}
+
+// Add records a new known issue with the next unused id, and returns it.
+func (r *Registry) Add(issue Issue) (Issue, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	issue.ID = 1
+	for _, existing := range r.issues {
+		if existing.ID >= issue.ID {
+			issue.ID = existing.ID + 1
+		}
+	}
+	issues := append(append([]Issue{}, r.issues...), issue)
+	return issue, r.replace(issues)
+}
+
+// Update replaces the known issue with the same id.
+func (r *Registry) Update(issue Issue) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	issues := append([]Issue{}, r.issues...)
+	for i := range issues {
+		if issues[i].ID == issue.ID {
+			issues[i] = issue
+			return r.replace(issues)
+		}
+	}
+	return fmt.Errorf("no known issue with id %d", issue.ID)
+}
+
+// Delete removes the known issue with the id.
+func (r *Registry) Delete(id int) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	issues := []Issue{}
+	for _, issue := range r.issues {
+		if issue.ID != id {
+			issues = append(issues, issue)
+		}
+	}
+	if len(issues) == len(r.issues) {
+		return fmt.Errorf("no known issue with id %d", id)
+	}
+	return r.replace(issues)
+}
+
+// replace validates the issues, writes them to the file and starts using them.  It is called with the lock held.
+func (r *Registry) replace(issues []Issue) error {
+	if len(r.path) == 0 {
+		return fmt.Errorf("known issues can't be changed without a known issues file")
+	}
+	regexes, err := compile(issues)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(issues)
+	if err != nil {
+		return err
+	}
+	// rename is atomic, so the file is never left half written.
+	tmp := filepath.Join(filepath.Dir(r.path), "."+filepath.Base(r.path)+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+	r.issues, r.regexes = issues, regexes
+	return nil
+}
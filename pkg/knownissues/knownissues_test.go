@@ -0,0 +1,246 @@
+package knownissues
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func newRegistry(t *testing.T, issues ...Issue) (*Registry, func()) {
+	dir, err := ioutil.TempDir("", "knownissues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Load(filepath.Join(dir, "known-issues.yaml"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	for _, issue := range issues {
+		if _, err := r.Add(issue); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return r, func() { os.RemoveAll(dir) }
+}
+
+func TestRegistryChanges(t *testing.T) {
+	operator := Issue{Pattern: `^operator\.Run`, Bug: "1825255", Expires: "2020-06-30"}
+	network := Issue{Pattern: `\[sig-network\]`, Bug: "https://bugzilla.redhat.com/show_bug.cgi?id=1830000", Expires: "2020-07-31"}
+
+	tests := []struct {
+		name   string
+		change func(r *Registry) error
+		issues []Issue
+		err    bool
+	}{
+		{
+			name: "add numbers the issues",
+			change: func(r *Registry) error {
+				_, err := r.Add(network)
+				return err
+			},
+			issues: []Issue{withID(operator, 1), withID(network, 2)},
+		},
+		{
+			name: "add after a delete uses a new id",
+			change: func(r *Registry) error {
+				if err := r.Delete(1); err != nil {
+					return err
+				}
+				if _, err := r.Add(network); err != nil {
+					return err
+				}
+				_, err := r.Add(operator)
+				return err
+			},
+			issues: []Issue{withID(network, 1), withID(operator, 2)},
+		},
+		{
+			name: "update",
+			change: func(r *Registry) error {
+				return r.Update(withID(network, 1))
+			},
+			issues: []Issue{withID(network, 1)},
+		},
+		{
+			name: "update of a missing issue",
+			change: func(r *Registry) error {
+				return r.Update(withID(network, 2))
+			},
+			issues: []Issue{withID(operator, 1)},
+			err:    true,
+		},
+		{
+			name: "delete",
+			change: func(r *Registry) error {
+				return r.Delete(1)
+			},
+			issues: []Issue{},
+		},
+		{
+			name: "delete of a missing issue",
+			change: func(r *Registry) error {
+				return r.Delete(2)
+			},
+			issues: []Issue{withID(operator, 1)},
+			err:    true,
+		},
+		{
+			name: "invalid pattern",
+			change: func(r *Registry) error {
+				_, err := r.Add(Issue{Pattern: "(", Bug: "1825255", Expires: "2020-06-30"})
+				return err
+			},
+			issues: []Issue{withID(operator, 1)},
+			err:    true,
+		},
+		{
+			name: "invalid bug",
+			change: func(r *Registry) error {
+				return r.Update(Issue{ID: 1, Pattern: "^operator", Bug: "https://github.com/openshift/origin/issues/1", Expires: "2020-06-30"})
+			},
+			issues: []Issue{withID(operator, 1)},
+			err:    true,
+		},
+		{
+			name: "invalid expiry",
+			change: func(r *Registry) error {
+				_, err := r.Add(Issue{Pattern: "^operator", Bug: "1825255", Expires: "06/30/2020"})
+				return err
+			},
+			issues: []Issue{withID(operator, 1)},
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, cleanup := newRegistry(t, operator)
+			defer cleanup()
+
+			if err := tt.change(r); tt.err != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tt.err, err)
+			}
+			if issues := r.Issues(); !reflect.DeepEqual(issues, tt.issues) {
+				t.Errorf("expected issues %+v, got %+v", tt.issues, issues)
+			}
+			// the changes are kept in the file
+			if err := r.Reload(); err != nil {
+				t.Fatal(err)
+			}
+			if issues := r.Issues(); !reflect.DeepEqual(issues, tt.issues) {
+				t.Errorf("expected issues %+v after reloading, got %+v", tt.issues, issues)
+			}
+		})
+	}
+}
+
+func withID(issue Issue, id int) Issue {
+	issue.ID = id
+	return issue
+}
+
+func TestRegistryMatch(t *testing.T) {
+	r, cleanup := newRegistry(t,
+		Issue{Pattern: `^operator\.Run template`, Bug: "1825255", Expires: "2020-06-30"},
+		Issue{Pattern: `^operator\.`, Bug: "1825256", Expires: "2020-06-30"},
+		Issue{Pattern: `\[sig-network\]`, Bug: "1830000", Expires: "2020-06-14"},
+		Issue{Pattern: `\[sig-storage\]`, Bug: "1830001", Expires: "2020-06-15"},
+	)
+	defer cleanup()
+
+	tests := []struct {
+		test  string
+		id    int
+		match bool
+	}{
+		{test: "operator.Run template e2e-aws - e2e-aws container test", id: 1, match: true},
+		{test: "operator.Install", id: 2, match: true},
+		{test: "[sig-network] services should serve", match: false},
+		{test: "[sig-storage] volumes should mount", id: 4, match: true},
+		{test: "[sig-cli] oc should run", match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.test, func(t *testing.T) {
+			issue, ok := r.Match(tt.test, now)
+			if ok != tt.match || issue.ID != tt.id {
+				t.Errorf("expected issue %d matched %t, got issue %d matched %t", tt.id, tt.match, issue.ID, ok)
+			}
+		})
+	}
+}
+
+func TestRegistryStatuses(t *testing.T) {
+	r, cleanup := newRegistry(t,
+		Issue{Pattern: `^operator\.`, Bug: "1", Expires: "2020-06-30"},
+		Issue{Pattern: `\[sig-network\]`, Bug: "2", Expires: "2020-06-14"},
+		Issue{Pattern: `\[sig-storage\]`, Bug: "3", Expires: "2020-06-30"},
+		Issue{Pattern: `\[sig-cli\]`, Bug: "4", Expires: "2020-06-30"},
+		Issue{Pattern: `\[sig-apps\]`, Bug: "5", Expires: "2020-06-30"},
+	)
+	defer cleanup()
+
+	failing := []string{"operator.Run template", "[sig-network] b", "[sig-network] a", "operator.Install"}
+	bugStatuses := map[string]string{"1": "NEW", "2": "ASSIGNED", "3": "ON_QA", "4": "CLOSED"}
+	statuses := r.Statuses(failing, bugStatuses, now)
+
+	expected := []struct {
+		tests     []string
+		bugStatus string
+		bugClosed bool
+		expired   bool
+	}{
+		{tests: []string{"operator.Install", "operator.Run template"}, bugStatus: "NEW"},
+		{tests: []string{"[sig-network] a", "[sig-network] b"}, bugStatus: "ASSIGNED", expired: true},
+		{tests: []string{}, bugStatus: "ON_QA", bugClosed: true},
+		{tests: []string{}, bugStatus: "CLOSED", bugClosed: true},
+		{tests: []string{}},
+	}
+	if len(statuses) != len(expected) {
+		t.Fatalf("expected %d statuses, got %d", len(expected), len(statuses))
+	}
+	for i, e := range expected {
+		s := statuses[i]
+		if !reflect.DeepEqual(s.Tests, e.tests) || s.BugStatus != e.bugStatus || s.BugClosed != e.bugClosed || s.Expired != e.expired {
+			t.Errorf("expected status %d to be %+v, got %+v", i+1, e, s)
+		}
+	}
+}
+
+func TestBugClosed(t *testing.T) {
+	tests := map[string]bool{
+		"":                false,
+		"NEW":             false,
+		"ASSIGNED":        false,
+		"POST":            false,
+		"MODIFIED":        false,
+		"ON_QA":           true,
+		"VERIFIED":        true,
+		"RELEASE_PENDING": true,
+		"CLOSED":          true,
+	}
+	for status, closed := range tests {
+		if bugClosed(status) != closed {
+			t.Errorf("expected bug with status %q closed to be %t", status, closed)
+		}
+	}
+}
+
+func TestRegistryWithoutFile(t *testing.T) {
+	r, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Add(Issue{Pattern: "^operator", Bug: "1825255", Expires: "2020-06-30"}); err == nil {
+		t.Errorf("expected a registry without a file to reject changes")
+	}
+	if issues := r.Issues(); len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v", issues)
+	}
+}
@@ -0,0 +1,204 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// A data directory holds one subdirectory per fetch, named by the time the fetch started.  A snapshot is only
+// complete once its manifest has been written, after which the "current" symlink is flipped to point to it:
+//
+//	/data/current -> 20200601-120000
+//	/data/20200601-110000/manifest.json
+//	/data/20200601-120000/manifest.json
+//	/data/20200601-130000/              <- fetch in progress
+const (
+	CurrentLink  = "current"
+	ManifestFile = "manifest.json"
+
+	nameFormat = "20060102-150405"
+)
+
+type Manifest struct {
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Complete time.Time `json:"complete"`
+	Releases []string  `json:"releases"`
+	// Dashboards and Jobs are the number of each that were fetched, Failures lists any that could not be.
+	Dashboards int      `json:"dashboards"`
+	Jobs       int      `json:"jobs"`
+	Failures   []string `json:"failures,omitempty"`
+}
+
+// Create makes a new, incomplete, snapshot directory under root and returns its path.  Snapshots are named to the
+// second, so it fails if a snapshot was already created in the same second rather than fetch into it twice.
+func Create(root string, now time.Time) (string, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(root, now.UTC().Format(nameFormat))
+	if err := os.Mkdir(path, 0755); err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("snapshot %s already exists", path)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// Commit writes the manifest for the snapshot at path and atomically points the current link at it.
+func Commit(path string, manifest Manifest) error {
+	root, name := filepath.Split(filepath.Clean(path))
+	manifest.Name = name
+	manifest.Complete = time.Now()
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(path, ManifestFile+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(path, ManifestFile)); err != nil {
+		return err
+	}
+
+	// rename is atomic, so readers either see the old snapshot or the new one, never a missing link.
+	link := filepath.Join(root, CurrentLink+".tmp")
+	os.Remove(link)
+	if err := os.Symlink(name, link); err != nil {
+		return err
+	}
+	return os.Rename(link, filepath.Join(root, CurrentLink))
+}
+
+func readManifest(path string) (Manifest, error) {
+	manifest := Manifest{}
+	b, err := ioutil.ReadFile(filepath.Join(path, ManifestFile))
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(b, &manifest)
+	return manifest, err
+}
+
+// List returns the manifests of the complete snapshots under root, newest first.
+func List(root string) ([]Manifest, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	manifests := []Manifest{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := readManifest(filepath.Join(root, entry.Name()))
+		if err != nil {
+			continue
+		}
+		manifest.Name = entry.Name()
+		manifests = append(manifests, manifest)
+	}
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return manifests[i].Name > manifests[j].Name
+	})
+	return manifests, nil
+}
+
+// Resolve returns the directory to read data from.  If path holds snapshots, that is the named snapshot, or the
+// current one if name is empty.  If path is itself a snapshot it is used as is.  Directories that predate
+// snapshots (such as historical-data/4.4GA) have no manifest and are read directly.
+func Resolve(path, name string) (string, error) {
+	if len(name) != 0 {
+		snapshot := filepath.Join(path, name)
+		if _, err := readManifest(snapshot); err != nil {
+			return "", fmt.Errorf("snapshot %s in %s is not complete: %v", name, path, err)
+		}
+		return snapshot, nil
+	}
+
+	current := filepath.Join(path, CurrentLink)
+	if _, err := os.Lstat(current); err == nil {
+		snapshot, err := filepath.EvalSymlinks(current)
+		if err != nil {
+			return "", fmt.Errorf("unable to resolve current snapshot in %s: %v", path, err)
+		}
+		if _, err := readManifest(snapshot); err != nil {
+			return "", fmt.Errorf("current snapshot %s is not complete: %v", snapshot, err)
+		}
+		return snapshot, nil
+	}
+
+	if _, err := readManifest(path); err == nil {
+		return path, nil
+	}
+
+	manifests, err := List(path)
+	if err == nil && len(manifests) > 0 {
+		return filepath.Join(path, manifests[0].Name), nil
+	}
+
+	klog.V(2).Infof("No snapshots found in %s, reading it as a plain data directory\n", path)
+	return path, nil
+}
+
+// Prune removes all but the newest keep complete snapshots under root, along with any incomplete snapshots
+// older than the newest complete one.  The current snapshot is never removed.
+func Prune(root string, keep int) error {
+	if keep < 1 {
+		return fmt.Errorf("invalid number of snapshots to keep %d, must be at least 1", keep)
+	}
+	manifests, err := List(root)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		return nil
+	}
+
+	current, _ := filepath.EvalSymlinks(filepath.Join(root, CurrentLink))
+	complete := make(map[string]bool)
+	for i, manifest := range manifests {
+		complete[manifest.Name] = true
+		if i < keep {
+			continue
+		}
+		path := filepath.Join(root, manifest.Name)
+		if path == current {
+			continue
+		}
+		klog.V(2).Infof("Removing snapshot %s\n", path)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	newest := manifests[0].Name
+	for _, entry := range entries {
+		if !entry.IsDir() || complete[entry.Name()] || entry.Name() >= newest {
+			continue
+		}
+		if _, err := time.Parse(nameFormat, entry.Name()); err != nil {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		klog.V(2).Infof("Removing incomplete snapshot %s\n", path)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
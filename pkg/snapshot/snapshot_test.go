@@ -0,0 +1,135 @@
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name string
+		// complete snapshots are committed in order, so the last one is current.
+		complete   []string
+		incomplete []string
+		other      []string
+		keep       int
+		remaining  []string
+		err        bool
+	}{
+		{
+			name:      "keeps the newest",
+			complete:  []string{"20200601-100000", "20200601-110000", "20200601-120000", "20200601-130000"},
+			keep:      2,
+			remaining: []string{"20200601-120000", "20200601-130000"},
+		},
+		{
+			name:      "keeps all if there are fewer",
+			complete:  []string{"20200601-100000", "20200601-110000"},
+			keep:      5,
+			remaining: []string{"20200601-100000", "20200601-110000"},
+		},
+		{
+			name:      "keeps the current snapshot",
+			complete:  []string{"20200601-130000", "20200601-120000", "20200601-100000"},
+			keep:      1,
+			remaining: []string{"20200601-100000", "20200601-130000"},
+		},
+		{
+			name:       "removes incomplete snapshots older than the newest",
+			complete:   []string{"20200601-100000", "20200601-120000"},
+			incomplete: []string{"20200601-110000", "20200601-130000"},
+			keep:       2,
+			remaining:  []string{"20200601-100000", "20200601-120000", "20200601-130000"},
+		},
+		{
+			name:      "leaves other directories alone",
+			complete:  []string{"20200601-100000", "20200601-120000"},
+			other:     []string{"4.4GA", "20200101-notasnapshot"},
+			keep:      1,
+			remaining: []string{"20200101-notasnapshot", "20200601-120000", "4.4GA"},
+		},
+		{
+			name:      "nothing to prune",
+			other:     []string{"4.4GA"},
+			keep:      1,
+			remaining: []string{"4.4GA"},
+		},
+		{
+			name:      "keeps at least one",
+			complete:  []string{"20200601-100000"},
+			keep:      0,
+			remaining: []string{"20200601-100000"},
+			err:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "snapshot")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+
+			for _, name := range tt.complete {
+				if err := os.Mkdir(filepath.Join(root, name), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := Commit(filepath.Join(root, name), Manifest{Created: time.Now()}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range append(append([]string{}, tt.incomplete...), tt.other...) {
+				if err := os.Mkdir(filepath.Join(root, name), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = Prune(root, tt.keep)
+			if tt.err != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tt.err, err)
+			}
+			entries, err := ioutil.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			remaining := []string{}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					remaining = append(remaining, entry.Name())
+				}
+			}
+			sort.Strings(remaining)
+			if !reflect.DeepEqual(remaining, tt.remaining) {
+				t.Errorf("expected %v to remain, got %v", tt.remaining, remaining)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	root, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	path, err := Create(filepath.Join(root, "data"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(root, "data", "20200601-120000"); path != expected {
+		t.Errorf("expected snapshot %s, got %s", expected, path)
+	}
+	if _, err := Create(filepath.Join(root, "data"), now.Add(500*time.Millisecond)); err == nil {
+		t.Errorf("expected a second snapshot in the same second to be rejected")
+	}
+	if _, err := Create(filepath.Join(root, "data"), now.Add(time.Second)); err != nil {
+		t.Errorf("expected a snapshot a second later to be created, got %v", err)
+	}
+}
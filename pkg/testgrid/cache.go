@@ -0,0 +1,84 @@
+package testgrid
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bparees/sippy/pkg/cache"
+)
+
+// CachingDataSource keeps the data read from another data source in memory, so that repeated analyses of the same
+// data don't read and parse it again.  The job summaries of every dashboard are kept, along with the most recently
+// used job details.  The underlying data is assumed not to change, a new CachingDataSource should be created when
+// it does (e.g. for each snapshot).
+type CachingDataSource struct {
+	source DataSource
+
+	lock      sync.Mutex
+	summaries map[string]cachedSummaries
+	details   *cache.LRU
+}
+
+type cachedSummaries struct {
+	jobs    map[string]JobSummary
+	updated time.Time
+}
+
+// NewCachingDataSource caches the data from source, keeping the details of up to detailsSize jobs.
+func NewCachingDataSource(source DataSource, detailsSize int) *CachingDataSource {
+	return &CachingDataSource{
+		source:    source,
+		summaries: make(map[string]cachedSummaries),
+		details:   cache.NewLRU(detailsSize),
+	}
+}
+
+func (c *CachingDataSource) JobSummaries(dashboard string) (map[string]JobSummary, time.Time, error) {
+	c.lock.Lock()
+	cached, ok := c.summaries[dashboard]
+	c.lock.Unlock()
+	if ok {
+		return cached.jobs, cached.updated, nil
+	}
+
+	jobs, updated, err := c.source.JobSummaries(dashboard)
+	if err != nil {
+		return jobs, updated, err
+	}
+	c.lock.Lock()
+	c.summaries[dashboard] = cachedSummaries{jobs: jobs, updated: updated}
+	c.lock.Unlock()
+	return jobs, updated, nil
+}
+
+// JobDetails returns the cached details of the job.  The analysis rewrites the test names in place, so each caller
+// is given its own copy of the tests, the results of each test are shared and must not be modified.
+func (c *CachingDataSource) JobDetails(dashboard, jobName string) (JobDetails, error) {
+	key := dashboard + "/" + jobName
+	if cached, ok := c.details.Get(key); ok {
+		return copyTests(cached.(JobDetails)), nil
+	}
+
+	details, err := c.source.JobDetails(dashboard, jobName)
+	if err != nil {
+		return details, err
+	}
+	c.details.Add(key, details)
+	return copyTests(details), nil
+}
+
+// Close closes the underlying data source, if it holds anything open.
+func (c *CachingDataSource) Close() error {
+	if closer, ok := c.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func copyTests(details JobDetails) JobDetails {
+	tests := make([]Test, len(details.Tests))
+	copy(tests, details.Tests)
+	details.Tests = tests
+	return details
+}
@@ -0,0 +1,79 @@
+package testgrid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dashboard is a testgrid dashboard to analyze.  A %s in the name is replaced with the release.  Type labels the
+// jobs on the dashboard, e.g. blocking or informing.  Releases limits the dashboard to those releases, it is used
+// for every release if there are none.
+type Dashboard struct {
+	Name     string   `json:"name" yaml:"name"`
+	Type     string   `json:"type" yaml:"type"`
+	Releases []string `json:"releases,omitempty" yaml:"releases"`
+}
+
+// DefaultDashboards are the blocking and informing dashboards of the OpenShift releases.
+var DefaultDashboards = []Dashboard{
+	{Name: "redhat-openshift-ocp-release-%s-blocking", Type: "blocking"},
+	{Name: "redhat-openshift-ocp-release-%s-informing", Type: "informing"},
+}
+
+// ParseDashboard parses a dashboard of the form [RELEASE,...:]TYPE=NAME, e.g. blocking=sig-release-%s-blocking or
+// 4.6:okd=redhat-openshift-okd-release-4.6-informing.
+func ParseDashboard(value string) (Dashboard, error) {
+	dashboard := Dashboard{}
+	spec := value
+	if i := strings.Index(spec, ":"); i >= 0 && i < strings.Index(spec, "=") {
+		for _, release := range strings.Split(spec[:i], ",") {
+			if len(release) == 0 {
+				return Dashboard{}, fmt.Errorf("invalid dashboard %q, empty release", value)
+			}
+			dashboard.Releases = append(dashboard.Releases, release)
+		}
+		spec = spec[i+1:]
+	}
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return Dashboard{}, fmt.Errorf("invalid dashboard %q, expected [RELEASE,...:]TYPE=NAME", value)
+	}
+	dashboard.Type, dashboard.Name = parts[0], parts[1]
+	if strings.Count(dashboard.Name, "%") > 1 || (strings.Contains(dashboard.Name, "%") && !strings.Contains(dashboard.Name, "%s")) {
+		return Dashboard{}, fmt.Errorf("invalid dashboard %q, only a single %%s for the release is allowed in the name", value)
+	}
+	return dashboard, nil
+}
+
+func (d Dashboard) String() string {
+	s := d.Type + "=" + d.Name
+	if len(d.Releases) > 0 {
+		s = strings.Join(d.Releases, ",") + ":" + s
+	}
+	return s
+}
+
+// ReleaseDashboards are the dashboards used for the release, with the release filled in to their names.
+func ReleaseDashboards(dashboards []Dashboard, release string) []Dashboard {
+	result := []Dashboard{}
+	for _, d := range dashboards {
+		if len(d.Releases) > 0 && !contains(d.Releases, release) {
+			continue
+		}
+		name := d.Name
+		if strings.Contains(name, "%s") {
+			name = fmt.Sprintf(name, release)
+		}
+		result = append(result, Dashboard{Name: name, Type: d.Type, Releases: []string{release}})
+	}
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
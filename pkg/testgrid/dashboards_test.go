@@ -0,0 +1,92 @@
+package testgrid
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDashboard(t *testing.T) {
+	tests := []struct {
+		value     string
+		dashboard Dashboard
+		err       bool
+	}{
+		{
+			value:     "blocking=redhat-openshift-ocp-release-%s-blocking",
+			dashboard: Dashboard{Name: "redhat-openshift-ocp-release-%s-blocking", Type: "blocking"},
+		},
+		{
+			value:     "okd=redhat-openshift-okd-release-4.6-informing",
+			dashboard: Dashboard{Name: "redhat-openshift-okd-release-4.6-informing", Type: "okd"},
+		},
+		{
+			value:     "4.6:okd=redhat-openshift-okd-release-4.6-informing",
+			dashboard: Dashboard{Name: "redhat-openshift-okd-release-4.6-informing", Type: "okd", Releases: []string{"4.6"}},
+		},
+		{
+			value:     "4.5,4.6:informing=redhat-openshift-ocp-release-%s-informing",
+			dashboard: Dashboard{Name: "redhat-openshift-ocp-release-%s-informing", Type: "informing", Releases: []string{"4.5", "4.6"}},
+		},
+		{
+			// a colon after the type is part of the name
+			value:     "informing=dashboard:%s",
+			dashboard: Dashboard{Name: "dashboard:%s", Type: "informing"},
+		},
+		{value: "redhat-openshift-ocp-release-%s-blocking", err: true},
+		{value: "=redhat-openshift-ocp-release-%s-blocking", err: true},
+		{value: "blocking=", err: true},
+		{value: "4.5,:blocking=redhat-openshift-ocp-release-%s-blocking", err: true},
+		{value: "blocking=redhat-%s-openshift-%s", err: true},
+		{value: "blocking=redhat-openshift-%d", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			dashboard, err := ParseDashboard(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", dashboard)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dashboard, tt.dashboard) {
+				t.Errorf("expected %+v, got %+v", tt.dashboard, dashboard)
+			}
+			if s := dashboard.String(); s != tt.value {
+				t.Errorf("expected %q to format as itself, got %q", tt.value, s)
+			}
+		})
+	}
+}
+
+func TestReleaseDashboards(t *testing.T) {
+	dashboards := []Dashboard{
+		{Name: "redhat-openshift-ocp-release-%s-blocking", Type: "blocking"},
+		{Name: "redhat-openshift-okd-release-4.6-informing", Type: "okd", Releases: []string{"4.6"}},
+	}
+	tests := []struct {
+		release    string
+		dashboards []Dashboard
+	}{
+		{
+			release:    "4.5",
+			dashboards: []Dashboard{{Name: "redhat-openshift-ocp-release-4.5-blocking", Type: "blocking", Releases: []string{"4.5"}}},
+		},
+		{
+			release: "4.6",
+			dashboards: []Dashboard{
+				{Name: "redhat-openshift-ocp-release-4.6-blocking", Type: "blocking", Releases: []string{"4.6"}},
+				{Name: "redhat-openshift-okd-release-4.6-informing", Type: "okd", Releases: []string{"4.6"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			if result := ReleaseDashboards(dashboards, tt.release); !reflect.DeepEqual(result, tt.dashboards) {
+				t.Errorf("expected %+v, got %+v", tt.dashboards, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package testgrid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultURL is the public testgrid instance.
+	DefaultURL = "https://testgrid.k8s.io"
+)
+
+// DataSource provides testgrid job summaries and job details by dashboard.
+type DataSource interface {
+	// JobSummaries returns the summary of every job on the dashboard and the time the data was last updated.
+	JobSummaries(dashboard string) (map[string]JobSummary, time.Time, error)
+	// JobDetails returns the test results for a single job on the dashboard.
+	JobDetails(dashboard, jobName string) (JobDetails, error)
+}
+
+func SummaryURL(baseURL, dashboard string) string {
+	return fmt.Sprintf("%s/%s/summary", baseURL, dashboard)
+}
+
+func DetailsURL(baseURL, dashboard, jobName string) string {
+	return fmt.Sprintf("%s/%s/table?&show-stale-tests=&tab=%s", baseURL, dashboard, jobName)
+}
+
+// JobURL is the testgrid page for a job, as opposed to the json table behind it.
+func JobURL(baseURL, dashboard, jobName string) string {
+	return fmt.Sprintf("%s/%s#%s", baseURL, dashboard, jobName)
+}
+
+// LocalFilename is where the content of a testgrid url is stored on disk.  The name is always derived from the
+// public testgrid url so a data directory is laid out the same way regardless of where it was fetched from.
+func LocalFilename(storagePath, url string) string {
+	return storagePath + "/" + "\"" + strings.ReplaceAll(url, "/", "-") + "\""
+}
+
+// WriteLocalData stores the content of a testgrid url on disk in the layout expected by LocalDataSource.
+func WriteLocalData(storagePath, url string, data []byte) error {
+	f, err := os.Create(LocalFilename(storagePath, url))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+func decodeJobSummaries(b []byte) (map[string]JobSummary, error) {
+	jobs := make(map[string]JobSummary)
+	if err := json.NewDecoder(bytes.NewBuffer(b)).Decode(&jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func decodeJobDetails(b []byte, details *JobDetails) error {
+	return json.NewDecoder(bytes.NewBuffer(b)).Decode(details)
+}
+
+// LocalDataSource reads testgrid data previously downloaded to a directory.
+type LocalDataSource struct {
+	Path string
+	// URL is the testgrid instance used for links in the report.
+	URL string
+}
+
+func NewLocalDataSource(path, url string) *LocalDataSource {
+	if len(url) == 0 {
+		url = DefaultURL
+	}
+	return &LocalDataSource{Path: path, URL: url}
+}
+
+func (l *LocalDataSource) JobSummaries(dashboard string) (map[string]JobSummary, time.Time, error) {
+	filename := LocalFilename(l.Path, SummaryURL(DefaultURL, dashboard))
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return map[string]JobSummary{}, time.Time{}, fmt.Errorf("Could not read local data file %s: %v", filename, err)
+	}
+	f, err := os.Stat(filename)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	jobs, err := decodeJobSummaries(b)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return jobs, f.ModTime(), nil
+}
+
+func (l *LocalDataSource) JobDetails(dashboard, jobName string) (JobDetails, error) {
+	details := JobDetails{
+		Name: jobName,
+	}
+
+	filename := LocalFilename(l.Path, DetailsURL(DefaultURL, dashboard, jobName))
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return details, fmt.Errorf("Could not read local data file %s: %v", filename, err)
+	}
+	if err := decodeJobDetails(b, &details); err != nil {
+		return details, err
+	}
+	details.TestGridUrl = JobURL(l.URL, dashboard, jobName)
+	return details, nil
+}
+
+// HTTPDataSource fetches testgrid data live from a testgrid instance (or a mirror of one).
+type HTTPDataSource struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPDataSource(url string) *HTTPDataSource {
+	if len(url) == 0 {
+		url = DefaultURL
+	}
+	return &HTTPDataSource{URL: url, Client: http.DefaultClient}
+}
+
+// StatusError is returned when testgrid responds with something other than a 200.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Non-200 response code fetching %s: %d", e.URL, e.StatusCode)
+}
+
+func (h *HTTPDataSource) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// RawJobSummaries returns the unparsed summary json for the dashboard.
+func (h *HTTPDataSource) RawJobSummaries(ctx context.Context, dashboard string) ([]byte, error) {
+	return h.get(ctx, SummaryURL(h.URL, dashboard))
+}
+
+// RawJobDetails returns the unparsed table json for a job on the dashboard.
+func (h *HTTPDataSource) RawJobDetails(ctx context.Context, dashboard, jobName string) ([]byte, error) {
+	return h.get(ctx, DetailsURL(h.URL, dashboard, jobName))
+}
+
+func (h *HTTPDataSource) JobSummaries(dashboard string) (map[string]JobSummary, time.Time, error) {
+	b, err := h.RawJobSummaries(context.TODO(), dashboard)
+	if err != nil {
+		return map[string]JobSummary{}, time.Time{}, err
+	}
+	jobs, err := decodeJobSummaries(b)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return jobs, time.Now(), nil
+}
+
+func (h *HTTPDataSource) JobDetails(dashboard, jobName string) (JobDetails, error) {
+	details := JobDetails{
+		Name: jobName,
+	}
+	b, err := h.RawJobDetails(context.TODO(), dashboard, jobName)
+	if err != nil {
+		return details, err
+	}
+	if err := decodeJobDetails(b, &details); err != nil {
+		return details, err
+	}
+	details.TestGridUrl = JobURL(h.URL, dashboard, jobName)
+	return details, nil
+}
+
+// MemoryDataSource serves fixed testgrid data from memory, keyed by dashboard and then job name.
+type MemoryDataSource struct {
+	Summaries map[string]map[string]JobSummary
+	Details   map[string]map[string]JobDetails
+	Timestamp time.Time
+}
+
+func NewMemoryDataSource() *MemoryDataSource {
+	return &MemoryDataSource{
+		Summaries: make(map[string]map[string]JobSummary),
+		Details:   make(map[string]map[string]JobDetails),
+		Timestamp: time.Now(),
+	}
+}
+
+// AddJob registers the job details under the dashboard, along with a summary entry for the job.
+func (m *MemoryDataSource) AddJob(dashboard string, summary JobSummary, details JobDetails) {
+	if _, ok := m.Summaries[dashboard]; !ok {
+		m.Summaries[dashboard] = make(map[string]JobSummary)
+	}
+	if _, ok := m.Details[dashboard]; !ok {
+		m.Details[dashboard] = make(map[string]JobDetails)
+	}
+	m.Summaries[dashboard][details.Name] = summary
+	m.Details[dashboard][details.Name] = details
+}
+
+func (m *MemoryDataSource) JobSummaries(dashboard string) (map[string]JobSummary, time.Time, error) {
+	jobs, ok := m.Summaries[dashboard]
+	if !ok {
+		return map[string]JobSummary{}, time.Time{}, fmt.Errorf("no data for dashboard %s", dashboard)
+	}
+	return jobs, m.Timestamp, nil
+}
+
+func (m *MemoryDataSource) JobDetails(dashboard, jobName string) (JobDetails, error) {
+	details, ok := m.Details[dashboard][jobName]
+	if !ok {
+		return JobDetails{Name: jobName}, fmt.Errorf("no data for job %s on dashboard %s", jobName, dashboard)
+	}
+	details.Name = jobName
+	if len(details.TestGridUrl) == 0 {
+		details.TestGridUrl = JobURL(DefaultURL, dashboard, jobName)
+	}
+	// the analysis rewrites the test names in place, it must not change the stored data
+	return copyTests(details), nil
+}
@@ -0,0 +1,273 @@
+package testgrid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+type FetchOptions struct {
+	// Concurrency is the number of downloads in flight at once.
+	Concurrency int
+	// RequestsPerSecond is the maximum request rate against any single host, 0 for no limit.
+	RequestsPerSecond float64
+	// Retries is how many times a failed download is retried before giving up.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles on each subsequent retry.
+	Backoff time.Duration
+	// Timeout bounds each individual request.
+	Timeout time.Duration
+}
+
+// FetchFailure records a download that still failed after all retries.
+type FetchFailure struct {
+	Dashboard string
+	Job       string
+	URL       string
+	Err       error
+}
+
+type FetchSummary struct {
+	Started    time.Time
+	Dashboards int
+	Jobs       int
+	Failures   []FetchFailure
+}
+
+// Fetcher downloads dashboard summaries and job details from testgrid into a local data directory
+// using a pool of workers.
+type Fetcher struct {
+	source   *HTTPDataSource
+	options  FetchOptions
+	limiters map[string]*rateLimiter
+	lock     sync.Mutex
+}
+
+func NewFetcher(testGridURL string, options FetchOptions) *Fetcher {
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+	source := NewHTTPDataSource(testGridURL)
+	source.Client = &http.Client{Timeout: options.Timeout}
+	return &Fetcher{
+		source:   source,
+		options:  options,
+		limiters: make(map[string]*rateLimiter),
+	}
+}
+
+type fetchTask struct {
+	dashboard string
+	job       string
+	// url is the address being fetched, local is the url the data is stored under on disk.
+	url   string
+	local string
+	fetch func(ctx context.Context) ([]byte, error)
+}
+
+// Fetch downloads the summary of each dashboard, then the details of every job on those dashboards accepted
+// by the filter, into storagePath.  Individual download failures are reported in the summary; an error is only
+// returned if the context was cancelled.
+func (f *Fetcher) Fetch(ctx context.Context, dashboards []string, filter func(jobName string, job JobSummary) bool, storagePath string) (FetchSummary, error) {
+	summary := FetchSummary{Started: time.Now()}
+
+	tasks := []fetchTask{}
+	for _, dashboard := range dashboards {
+		dashboard := dashboard
+		tasks = append(tasks, fetchTask{
+			dashboard: dashboard,
+			url:       SummaryURL(f.source.URL, dashboard),
+			local:     SummaryURL(DefaultURL, dashboard),
+			fetch: func(ctx context.Context) ([]byte, error) {
+				return f.source.RawJobSummaries(ctx, dashboard)
+			},
+		})
+	}
+	failed := make(map[string]bool)
+	for _, failure := range f.run(ctx, tasks, storagePath) {
+		failed[failure.Dashboard] = true
+		summary.Failures = append(summary.Failures, failure)
+	}
+	summary.Dashboards = len(tasks)
+	if ctx.Err() != nil {
+		return summary, ctx.Err()
+	}
+
+	// the summaries have been written to disk, so read them back to determine which jobs to fetch.
+	local := NewLocalDataSource(storagePath, f.source.URL)
+	tasks = []fetchTask{}
+	for _, dashboard := range dashboards {
+		dashboard := dashboard
+		if failed[dashboard] {
+			continue
+		}
+		jobs, _, err := local.JobSummaries(dashboard)
+		if err != nil {
+			klog.V(2).Infof("Skipping jobs from dashboard %s: %v\n", dashboard, err)
+			continue
+		}
+		for jobName, job := range jobs {
+			jobName := jobName
+			if filter != nil && !filter(jobName, job) {
+				continue
+			}
+			tasks = append(tasks, fetchTask{
+				dashboard: dashboard,
+				job:       jobName,
+				url:       DetailsURL(f.source.URL, dashboard, jobName),
+				local:     DetailsURL(DefaultURL, dashboard, jobName),
+				fetch: func(ctx context.Context) ([]byte, error) {
+					return f.source.RawJobDetails(ctx, dashboard, jobName)
+				},
+			})
+		}
+	}
+	summary.Failures = append(summary.Failures, f.run(ctx, tasks, storagePath)...)
+	summary.Jobs = len(tasks)
+	return summary, ctx.Err()
+}
+
+func (f *Fetcher) run(ctx context.Context, tasks []fetchTask, storagePath string) []FetchFailure {
+	queue := make(chan fetchTask)
+	failures := make(chan FetchFailure, len(tasks))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < f.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				klog.V(4).Infof("Fetching %s\n", task.url)
+				b, err := f.fetchWithRetry(ctx, task)
+				if err == nil {
+					err = WriteLocalData(storagePath, task.local, b)
+				}
+				if err != nil {
+					klog.Errorf("Error fetching %s: %v\n", task.url, err)
+					failures <- FetchFailure{Dashboard: task.dashboard, Job: task.job, URL: task.url, Err: err}
+				}
+			}
+		}()
+	}
+
+	for _, task := range tasks {
+		select {
+		case queue <- task:
+		case <-ctx.Done():
+			failures <- FetchFailure{Dashboard: task.dashboard, Job: task.job, URL: task.url, Err: ctx.Err()}
+		}
+	}
+	close(queue)
+	wg.Wait()
+	close(failures)
+
+	result := []FetchFailure{}
+	for failure := range failures {
+		result = append(result, failure)
+	}
+	return result
+}
+
+func (f *Fetcher) fetchWithRetry(ctx context.Context, task fetchTask) ([]byte, error) {
+	limiter := f.limiterFor(task.url)
+	backoff := f.options.Backoff
+	var err error
+	for attempt := 0; attempt <= f.options.Retries; attempt++ {
+		if attempt > 0 {
+			klog.V(2).Infof("Retrying %s in %v (attempt %d): %v\n", task.url, backoff, attempt, err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			backoff *= 2
+		}
+		if err := limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		var b []byte
+		b, err = task.fetch(ctx)
+		if err == nil {
+			return b, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !retryable(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %v", f.options.Retries+1, err)
+}
+
+// retryable returns true for the errors that may go away on their own: network errors, timeouts, and responses
+// that ask to slow down or report a server side failure.  A 404 for a job missing from testgrid won't.
+func retryable(err error) bool {
+	statusErr, ok := err.(*StatusError)
+	if !ok {
+		return true
+	}
+	return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+}
+
+func (f *Fetcher) limiterFor(rawurl string) *rateLimiter {
+	host := rawurl
+	if u, err := url.Parse(rawurl); err == nil {
+		host = u.Host
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, ok := f.limiters[host]
+	if !ok {
+		l = newRateLimiter(f.options.RequestsPerSecond)
+		f.limiters[host] = l
+	}
+	return l
+}
+
+// rateLimiter spaces requests evenly so no more than the configured number are started per second.
+type rateLimiter struct {
+	interval time.Duration
+	next     time.Time
+	lock     sync.Mutex
+}
+
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	l := &rateLimiter{}
+	if requestsPerSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return l
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return ctx.Err()
+	}
+
+	l.lock.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.lock.Unlock()
+
+	if delay == 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
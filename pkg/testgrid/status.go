@@ -0,0 +1,82 @@
+package testgrid
+
+// TestStatus is the result testgrid records for a test in a single job run.  The values match the TestStatus enum
+// in testgrid's config proto.
+type TestStatus int
+
+const (
+	NoResult         TestStatus = 0
+	Pass             TestStatus = 1
+	PassWithErrors   TestStatus = 2
+	PassWithSkips    TestStatus = 3
+	Running          TestStatus = 4
+	CategorizedAbort TestStatus = 5
+	Unknown          TestStatus = 6
+	Cancel           TestStatus = 7
+	Blocked          TestStatus = 8
+	TimedOut         TestStatus = 9
+	CategorizedFail  TestStatus = 10
+	BuildFail        TestStatus = 11
+	Fail             TestStatus = 12
+	Flaky            TestStatus = 13
+	ToolFail         TestStatus = 14
+	BuildPassed      TestStatus = 15
+)
+
+var statusNames = map[TestStatus]string{
+	NoResult:         "NO_RESULT",
+	Pass:             "PASS",
+	PassWithErrors:   "PASS_WITH_ERRORS",
+	PassWithSkips:    "PASS_WITH_SKIPS",
+	Running:          "RUNNING",
+	CategorizedAbort: "CATEGORIZED_ABORT",
+	Unknown:          "UNKNOWN",
+	Cancel:           "CANCEL",
+	Blocked:          "BLOCKED",
+	TimedOut:         "TIMED_OUT",
+	CategorizedFail:  "CATEGORIZED_FAIL",
+	BuildFail:        "BUILD_FAIL",
+	Fail:             "FAIL",
+	Flaky:            "FLAKY",
+	ToolFail:         "TOOL_FAIL",
+	BuildPassed:      "BUILD_PASSED",
+}
+
+func (s TestStatus) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// IsPass is true for runs where the test passed, including flaky runs which passed on a retry.
+func (s TestStatus) IsPass() bool {
+	switch s {
+	case Pass, PassWithErrors, PassWithSkips, BuildPassed, Flaky:
+		return true
+	}
+	return false
+}
+
+// IsFailure is true for runs where the test failed, including runs that timed out.
+func (s TestStatus) IsFailure() bool {
+	switch s {
+	case Fail, CategorizedFail, BuildFail, ToolFail, CategorizedAbort, TimedOut:
+		return true
+	}
+	return false
+}
+
+func (s TestStatus) IsFlake() bool {
+	return s == Flaky
+}
+
+func (s TestStatus) IsTimeout() bool {
+	return s == TimedOut
+}
+
+// Counted is false for runs with no result for the test, or a result that says nothing about the test (such as a
+// run that is still in progress or was cancelled).  Those runs are not counted as passes or failures.
+func (s TestStatus) Counted() bool {
+	return s.IsPass() || s.IsFailure()
+}
@@ -14,6 +14,8 @@ type JobDetails struct {
 	ChangeLists []string `json:"changelists"`
 	// not part of testgrid json, but we want to store the url of the testgrid job page for later usage
 	TestGridUrl string
+	// DashboardType is the type of the dashboard the job was loaded from, e.g. blocking or informing
+	DashboardType string `json:"-"`
 }
 
 type Test struct {
@@ -22,6 +24,6 @@ type Test struct {
 }
 
 type TestResult struct {
-	Count int `json:"count"`
-	Value int `json:"value"`
+	Count int        `json:"count"`
+	Value TestStatus `json:"value"`
 }
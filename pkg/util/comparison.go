@@ -0,0 +1,173 @@
+package util
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var releaseRegex = regexp.MustCompile(`^(\d+\.\d+)`)
+
+// PassRate is a pass percentage and the number of runs it was computed from.
+type PassRate struct {
+	Successes      int     `json:"successes"`
+	Failures       int     `json:"failures"`
+	PassPercentage float64 `json:"passPercentage"`
+}
+
+func NewPassRate(successes, failures int) PassRate {
+	return PassRate{
+		Successes:      successes,
+		Failures:       failures,
+		PassPercentage: Percent(successes, failures),
+	}
+}
+
+func (p PassRate) Runs() int {
+	return p.Successes + p.Failures
+}
+
+// Comparison is the pass rate of a test, job or variant value now and in the baseline.  Baseline is nil if the
+// baseline has no runs of it.
+type Comparison struct {
+	Name     string    `json:"name"`
+	Current  PassRate  `json:"current"`
+	Baseline *PassRate `json:"baseline"`
+}
+
+// Delta is the change in pass percentage relative to the baseline, negative for a regression.
+func (c Comparison) Delta() float64 {
+	if c.Baseline == nil {
+		return 0
+	}
+	return c.Current.PassPercentage - c.Baseline.PassPercentage
+}
+
+type BaselineComparison struct {
+	Release         string       `json:"release"`
+	Baseline        string       `json:"baseline"`
+	BaselineRelease string       `json:"baselineRelease"`
+	TestPassRate    Comparison   `json:"testPassRate"`
+	JobPassRate     Comparison   `json:"jobPassRate"`
+	Tests           []Comparison `json:"tests"`
+	Jobs            []Comparison `json:"jobs"`
+	Variants        []Comparison `json:"variants"`
+	Timestamp       time.Time    `json:"timestamp"`
+}
+
+// ReleaseFromName extracts the release from a name such as a historical snapshot name, e.g. 4.4 from 4.4GA.
+func ReleaseFromName(name string) string {
+	match := releaseRegex.FindStringSubmatch(name)
+	if len(match) > 1 {
+		return match[1]
+	}
+	return ""
+}
+
+// NormalizeJobName removes the release from a job name, so the same job can be matched across releases,
+// e.g. release-openshift-ocp-installer-e2e-aws-4.4 becomes release-openshift-ocp-installer-e2e-aws.
+func NormalizeJobName(name, release string) string {
+	if len(release) == 0 {
+		return name
+	}
+	name = strings.ReplaceAll(name, "-"+release+"-", "-")
+	return strings.TrimSuffix(name, "-"+release)
+}
+
+// sortComparisons orders the largest regressions first, followed by everything missing from the baseline.
+func sortComparisons(comparisons []Comparison) {
+	sort.SliceStable(comparisons, func(i, j int) bool {
+		if (comparisons[i].Baseline == nil) != (comparisons[j].Baseline == nil) {
+			return comparisons[j].Baseline == nil
+		}
+		if comparisons[i].Delta() == comparisons[j].Delta() {
+			return comparisons[i].Name < comparisons[j].Name
+		}
+		return comparisons[i].Delta() < comparisons[j].Delta()
+	})
+}
+
+func totalJobPassRate(report TestReport) PassRate {
+	successes, failures := 0, 0
+	for _, job := range report.JobPassRate {
+		successes += job.Successes
+		failures += job.Failures
+	}
+	return NewPassRate(successes, failures)
+}
+
+// CompareToBaseline compares the pass rates of every test, job and variant value in the current report to the baseline
+// report.  Tests are compared using the unfiltered results (the reports only hold the failing tests), ignoring tests
+// with fewer than minRuns runs and the ones ignored by the rules.  Variants are compared in the dimensions of the rules.
+func CompareToBaseline(current, baseline TestReport, currentAll, baselineAll AggregateTestResult, baselineName string, rules *Rules, minRuns int) BaselineComparison {
+	comparison := BaselineComparison{
+		Release:         current.Release,
+		Baseline:        baselineName,
+		BaselineRelease: baseline.Release,
+		TestPassRate:    Comparison{Name: "All Tests", Current: NewPassRate(currentAll.Successes, currentAll.Failures)},
+		JobPassRate:     Comparison{Name: "All Jobs", Current: totalJobPassRate(current)},
+		Tests:           []Comparison{},
+		Jobs:            []Comparison{},
+		Variants:        []Comparison{},
+		Timestamp:       current.Timestamp,
+	}
+	baselineTests := NewPassRate(baselineAll.Successes, baselineAll.Failures)
+	comparison.TestPassRate.Baseline = &baselineTests
+	baselineJobRuns := totalJobPassRate(baseline)
+	comparison.JobPassRate.Baseline = &baselineJobRuns
+
+	for name, test := range currentAll.TestResults {
+		if test.Successes+test.Failures < minRuns || rules.IgnoreTest(name) {
+			continue
+		}
+		c := Comparison{
+			Name:    name,
+			Current: NewPassRate(test.Successes, test.Failures),
+		}
+		if prev, ok := baselineAll.TestResults[name]; ok && prev.Successes+prev.Failures >= minRuns {
+			p := NewPassRate(prev.Successes, prev.Failures)
+			c.Baseline = &p
+		}
+		comparison.Tests = append(comparison.Tests, c)
+	}
+	sortComparisons(comparison.Tests)
+
+	baselineJobs := make(map[string]JobResult)
+	for _, job := range SummarizeJobsByName(baseline) {
+		baselineJobs[NormalizeJobName(job.Name, baseline.Release)] = job
+	}
+	for _, job := range SummarizeJobsByName(current) {
+		c := Comparison{
+			Name:    job.Name,
+			Current: NewPassRate(job.Successes, job.Failures),
+		}
+		if prev, ok := baselineJobs[NormalizeJobName(job.Name, current.Release)]; ok {
+			p := NewPassRate(prev.Successes, prev.Failures)
+			c.Baseline = &p
+		}
+		comparison.Jobs = append(comparison.Jobs, c)
+	}
+	sortComparisons(comparison.Jobs)
+
+	for _, dimension := range rules.VariantDimensions() {
+		baselineVariants := make(map[string]JobResult)
+		for _, variant := range SummarizeJobsByVariant(baseline, dimension) {
+			baselineVariants[variant.Variant] = variant
+		}
+		for _, variant := range SummarizeJobsByVariant(current, dimension) {
+			c := Comparison{
+				Name:    VariantName(dimension, variant.Variant),
+				Current: NewPassRate(variant.Successes, variant.Failures),
+			}
+			if prev, ok := baselineVariants[variant.Variant]; ok {
+				p := NewPassRate(prev.Successes, prev.Failures)
+				c.Baseline = &p
+			}
+			comparison.Variants = append(comparison.Variants, c)
+		}
+	}
+	sortComparisons(comparison.Variants)
+
+	return comparison
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"sort"
+)
+
+// CanaryPassPercentage is the pass rate above which a test is considered a canary: a test that almost always
+// passes, so a run where it failed was probably disrupted by something other than the test itself.
+const CanaryPassPercentage = 99.0
+
+// JobRunSummary is a single job run and the overall pass rate of each test that failed in it.
+type JobRunSummary struct {
+	Release        string `json:"release"`
+	Job            string `json:"job"`
+	Url            string `json:"url"`
+	TestGridJobUrl string `json:"testGridJobUrl"`
+	Failed         bool   `json:"failed"`
+	Succeeded      bool   `json:"succeeded"`
+	// TestCount is the number of tests with a result in the run.
+	TestCount      int          `json:"testCount"`
+	FailedTests    []FailedTest `json:"failedTests"`
+	CanaryFailures int          `json:"canaryFailures"`
+}
+
+// FailedTest is a test that failed in a job run, along with its pass rate across every job.  Canary is set if the
+// test normally passes.
+type FailedTest struct {
+	Name string `json:"name"`
+	PassRate
+	Canary bool `json:"canary"`
+}
+
+// SummarizeJobRun lists the tests that failed in the run with their pass rates from all, the results of each test
+// across every job.  Tests with at least minRuns runs that pass more than CanaryPassPercentage of the time are
+// flagged as canaries, and are listed first.
+func SummarizeJobRun(release string, run JobRunResult, all AggregateTestResult, minRuns int) JobRunSummary {
+	summary := JobRunSummary{
+		Release:        release,
+		Job:            run.Job,
+		Url:            run.Url,
+		TestGridJobUrl: run.TestGridJobUrl,
+		Failed:         run.Failed,
+		Succeeded:      run.Succeeded,
+		TestCount:      len(run.TestNames),
+		FailedTests:    []FailedTest{},
+	}
+	for _, name := range run.FailedTestNames {
+		// the result of the Overall test is the result of the run itself
+		if name == "Overall" {
+			continue
+		}
+		result := all.TestResults[name]
+		test := FailedTest{
+			Name:     name,
+			PassRate: NewPassRate(result.Successes, result.Failures),
+		}
+		test.Canary = test.Runs() >= minRuns && test.PassPercentage > CanaryPassPercentage
+		if test.Canary {
+			summary.CanaryFailures++
+		}
+		summary.FailedTests = append(summary.FailedTests, test)
+	}
+	sort.SliceStable(summary.FailedTests, func(i, j int) bool {
+		a, b := summary.FailedTests[i], summary.FailedTests[j]
+		if a.PassPercentage != b.PassPercentage {
+			return a.PassPercentage > b.PassPercentage
+		}
+		return a.Name < b.Name
+	})
+	return summary
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"sort"
+	"time"
+)
+
+// JobSummary is the run history of a single job and the tests that fail most often in it.
+type JobSummary struct {
+	Name        string `json:"name"`
+	Release     string `json:"release"`
+	TestGridUrl string `json:"testGridUrl"`
+	// DashboardType is the type of the dashboard the job is on, e.g. blocking or informing.
+	DashboardType string   `json:"dashboardType"`
+	Variant       Variant  `json:"variant"`
+	PassRate      PassRate `json:"passRate"`
+	// PrevPassRate is the pass rate in the comparison period, nil if the job did not run then.
+	PrevPassRate *PassRate `json:"prevPassRate"`
+	// Runs are the runs of the job, newest first.  FailureGroups is the number of them that are failure groups.
+	Runs          []JobRun     `json:"runs"`
+	FailureGroups int          `json:"failureGroups"`
+	WorstTests    []TestResult `json:"worstTests"`
+}
+
+// JobRun is the outcome of a single run of a job.  FailureGroup is set if more tests failed in the run than the
+// failure cluster threshold, which usually means the run was disrupted by a cluster or infrastructure problem.
+type JobRun struct {
+	Url          string    `json:"url"`
+	Timestamp    time.Time `json:"timestamp"`
+	Failed       bool      `json:"failed"`
+	Succeeded    bool      `json:"succeeded"`
+	TestFailures int       `json:"testFailures"`
+	FailureGroup bool      `json:"failureGroup"`
+}
+
+// jobPassRate is the pass rate of the job's runs, and whether the job ran at all.
+func jobPassRate(name string, runs map[string]JobRunResult) (PassRate, bool) {
+	successes, failures, found := 0, 0, false
+	for _, run := range runs {
+		if run.Job != name {
+			continue
+		}
+		found = true
+		if run.Failed {
+			failures++
+		} else if run.Succeeded {
+			successes++
+		}
+	}
+	return NewPassRate(successes, failures), found
+}
+
+// SummarizeJob lists the runs of the job from the job runs of the release, compares its pass rate to the runs of
+// the comparison period, and lists up to testCount of the tests with at least minRuns runs that have the lowest
+// pass rates in the job.  It returns false if the job has no runs in the release.
+func SummarizeJob(name, release string, runs, prevRuns map[string]JobRunResult, byJob map[string]AggregateTestResult, rules *Rules, minRuns, failureClusterThreshold, testCount int) (JobSummary, bool) {
+	passRate, ok := jobPassRate(name, runs)
+	if !ok {
+		return JobSummary{}, false
+	}
+	summary := JobSummary{
+		Name:       name,
+		Release:    release,
+		PassRate:   passRate,
+		Runs:       []JobRun{},
+		WorstTests: []TestResult{},
+	}
+	if prev, ok := jobPassRate(name, prevRuns); ok {
+		summary.PrevPassRate = &prev
+	}
+
+	for _, run := range runs {
+		if run.Job != name {
+			continue
+		}
+		summary.TestGridUrl = run.TestGridJobUrl
+		summary.DashboardType = run.DashboardType
+		summary.Variant = run.Variant
+		jobRun := JobRun{
+			Url:          run.Url,
+			Timestamp:    run.Timestamp,
+			Failed:       run.Failed,
+			Succeeded:    run.Succeeded,
+			TestFailures: run.TestFailures,
+			// -1 disables failure groups
+			FailureGroup: failureClusterThreshold >= 0 && run.TestFailures > failureClusterThreshold,
+		}
+		if jobRun.FailureGroup {
+			summary.FailureGroups++
+		}
+		summary.Runs = append(summary.Runs, jobRun)
+	}
+	sort.SliceStable(summary.Runs, func(i, j int) bool {
+		a, b := summary.Runs[i], summary.Runs[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.Url < b.Url
+	})
+
+	for _, test := range byJob[name].TestResults {
+		if test.Failures == 0 || test.Successes+test.Failures < minRuns || rules.IgnoreTest(test.Name) {
+			continue
+		}
+		summary.WorstTests = append(summary.WorstTests, test)
+	}
+	sort.SliceStable(summary.WorstTests, func(i, j int) bool {
+		a, b := summary.WorstTests[i], summary.WorstTests[j]
+		if a.PassPercentage != b.PassPercentage {
+			return a.PassPercentage < b.PassPercentage
+		}
+		return a.Name < b.Name
+	})
+	if len(summary.WorstTests) > testCount {
+		summary.WorstTests = summary.WorstTests[:testCount]
+	}
+	return summary, true
+}
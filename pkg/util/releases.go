@@ -0,0 +1,140 @@
+package util
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReleaseComparison puts the pass rates of the same tests, jobs and variant values in several releases side by
+// side.  The releases are ordered oldest first, the pass rates of each row are in the same order.
+type ReleaseComparison struct {
+	Releases  []string     `json:"releases"`
+	Tests     []ReleaseRow `json:"tests"`
+	Jobs      []ReleaseRow `json:"jobs"`
+	Variants  []ReleaseRow `json:"variants"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// ReleaseRow is the pass rate of a test, job or variant value in each release, nil for the releases it has no runs
+// in.  Delta is the change in pass percentage in the newest release from the one before it, nil unless it ran in
+// both.
+type ReleaseRow struct {
+	Name      string      `json:"name"`
+	PassRates []*PassRate `json:"passRates"`
+	Delta     *float64    `json:"delta"`
+}
+
+// ReleaseReport is the report of one of the releases being compared, along with the unfiltered results of every
+// test in it (the report only holds the failing tests).
+type ReleaseReport struct {
+	Report TestReport
+	All    AggregateTestResult
+}
+
+// SortReleases orders release versions such as 4.4 and 4.10 oldest first.
+func SortReleases(releases []string) {
+	version := func(release string) []int {
+		parts := []int{}
+		for _, p := range strings.Split(release, ".") {
+			i, _ := strconv.Atoi(p)
+			parts = append(parts, i)
+		}
+		return parts
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		a, b := version(releases[i]), version(releases[j])
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return releases[i] < releases[j]
+	})
+}
+
+// releaseRows collects the pass rate of each name in each of n releases.
+type releaseRows map[string]*ReleaseRow
+
+func (r releaseRows) add(name string, release, n int, passRate PassRate) {
+	row, ok := r[name]
+	if !ok {
+		row = &ReleaseRow{Name: name, PassRates: make([]*PassRate, n)}
+		r[name] = row
+	}
+	row.PassRates[release] = &passRate
+}
+
+// sorted computes the change in the newest release and orders the largest regressions first, followed by the rows
+// that did not run in both of the newest two releases.
+func (r releaseRows) sorted() []ReleaseRow {
+	rows := []ReleaseRow{}
+	for _, row := range r {
+		if n := len(row.PassRates); n > 1 && row.PassRates[n-1] != nil && row.PassRates[n-2] != nil {
+			delta := row.PassRates[n-1].PassPercentage - row.PassRates[n-2].PassPercentage
+			row.Delta = &delta
+		}
+		rows = append(rows, *row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if (a.Delta == nil) != (b.Delta == nil) {
+			return b.Delta == nil
+		}
+		if a.Delta != nil && *a.Delta != *b.Delta {
+			return *a.Delta < *b.Delta
+		}
+		return a.Name < b.Name
+	})
+	return rows
+}
+
+// CompareReleases compares the pass rates of every test, job and variant value across the releases.  Jobs are matched
+// by their names without the release (see NormalizeJobName), and tests with fewer than minRuns runs in a release are
+// left out for that release, as are the tests ignored by the rules.  Variants are compared in the dimensions of the rules.
+func CompareReleases(releases []ReleaseReport, rules *Rules, minRuns int) ReleaseComparison {
+	sorted := make([]ReleaseReport, len(releases))
+	copy(sorted, releases)
+	names := []string{}
+	for _, r := range sorted {
+		names = append(names, r.Report.Release)
+	}
+	SortReleases(names)
+	for i, name := range names {
+		for _, r := range releases {
+			if r.Report.Release == name {
+				sorted[i] = r
+			}
+		}
+	}
+
+	comparison := ReleaseComparison{Releases: names}
+	tests, jobs, variants := releaseRows{}, releaseRows{}, releaseRows{}
+	for i, r := range sorted {
+		if r.Report.Timestamp.After(comparison.Timestamp) {
+			comparison.Timestamp = r.Report.Timestamp
+		}
+		for name, test := range r.All.TestResults {
+			if test.Successes+test.Failures < minRuns || rules.IgnoreTest(name) {
+				continue
+			}
+			tests.add(name, i, len(sorted), NewPassRate(test.Successes, test.Failures))
+		}
+		for _, job := range SummarizeJobsByName(r.Report) {
+			jobs.add(NormalizeJobName(job.Name, r.Report.Release), i, len(sorted), NewPassRate(job.Successes, job.Failures))
+		}
+		for _, dimension := range rules.VariantDimensions() {
+			for _, variant := range SummarizeJobsByVariant(r.Report, dimension) {
+				variants.add(VariantName(dimension, variant.Variant), i, len(sorted), NewPassRate(variant.Successes, variant.Failures))
+			}
+		}
+	}
+	comparison.Tests = tests.sorted()
+	comparison.Jobs = jobs.sorted()
+	comparison.Variants = variants.sorted()
+	return comparison
+}
@@ -0,0 +1,266 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// VariantRule sets the Dimension of the variant of the jobs whose names match Pattern to Value.  The first rule of a
+// dimension that matches a job wins, a rule without a pattern matches every job and so is the default of the dimension.
+type VariantRule struct {
+	Dimension string `json:"dimension" yaml:"dimension"`
+	Value     string `json:"value" yaml:"value"`
+	Pattern   string `json:"pattern,omitempty" yaml:"pattern"`
+}
+
+// Variant is the value of each dimension of a job, e.g. cloud=aws, arch=amd64 and network=ovn.
+type Variant map[string]string
+
+// OwnerRule assigns the test named Test, or the tests whose names match Pattern, to a sig, component and team.  A
+// rule that leaves the sig out keeps the sig from the [sig-...] tag of the test name.
+type OwnerRule struct {
+	Test      string `json:"test,omitempty" yaml:"test"`
+	Pattern   string `json:"pattern,omitempty" yaml:"pattern"`
+	Sig       string `json:"sig,omitempty" yaml:"sig"`
+	Component string `json:"component,omitempty" yaml:"component"`
+	Team      string `json:"team,omitempty" yaml:"team"`
+}
+
+// Owner is the sig, component and team a test belongs to.
+type Owner struct {
+	Sig       string `json:"sig"`
+	Component string `json:"component"`
+	Team      string `json:"team"`
+}
+
+var (
+	// DefaultIgnoreTests are left out of the failing and flaky test lists, they are steps of the job rather than tests.
+	DefaultIgnoreTests = []string{`operator.Run template`, `Monitor cluster while tests execute`, `Overall`, `job.initialize`}
+
+	// DefaultVariantRules describe the OpenShift release jobs.
+	DefaultVariantRules = []VariantRule{
+		{Dimension: "cloud", Value: "aws", Pattern: `(?i)-aws-`},
+		{Dimension: "cloud", Value: "azure", Pattern: `(?i)-azure-`},
+		{Dimension: "cloud", Value: "gcp", Pattern: `(?i)-gcp-`},
+		{Dimension: "cloud", Value: "openstack", Pattern: `(?i)-openstack-`},
+		{Dimension: "cloud", Value: "metal", Pattern: `(?i)-metal-`},
+		{Dimension: "cloud", Value: "ovirt", Pattern: `(?i)-ovirt-`},
+		{Dimension: "cloud", Value: "vsphere", Pattern: `(?i)-vsphere-`},
+		{Dimension: "cloud", Value: "unknown"},
+		{Dimension: "arch", Value: "ppc64le", Pattern: `(?i)-ppc64le(-|$)`},
+		{Dimension: "arch", Value: "s390x", Pattern: `(?i)-s390x(-|$)`},
+		{Dimension: "arch", Value: "arm64", Pattern: `(?i)-(arm64|aarch64)(-|$)`},
+		{Dimension: "arch", Value: "amd64"},
+		{Dimension: "network", Value: "ovn", Pattern: `(?i)-ovn-`},
+		{Dimension: "network", Value: "sdn"},
+		{Dimension: "topology", Value: "compact", Pattern: `(?i)-compact-`},
+		{Dimension: "topology", Value: "single-node", Pattern: `(?i)-single-node-`},
+		{Dimension: "topology", Value: "ha"},
+		{Dimension: "install", Value: "upi", Pattern: `(?i)-upi-`},
+		{Dimension: "install", Value: "proxy", Pattern: `(?i)-proxy-`},
+		{Dimension: "install", Value: "fips", Pattern: `(?i)-fips-`},
+		{Dimension: "install", Value: "ipi"},
+		{Dimension: "upgrade", Value: "upgrade", Pattern: `(?i)-upgrade-`},
+		{Dimension: "upgrade", Value: "none"},
+		{Dimension: "suite", Value: "serial", Pattern: `(?i)-serial-`},
+		{Dimension: "suite", Value: "parallel"},
+	}
+
+	// currentRules are used by the reports that are rendered from the analysis, see SetRules.
+	currentRules atomic.Value // *Rules
+)
+
+const (
+	// UnknownComponent and UnknownTeam are the owners of the tests no owner rule matches.
+	UnknownComponent = "component-unknown"
+	UnknownTeam      = "team-unknown"
+)
+
+// variantMatcher holds the compiled rules, and the dimensions in the order they first appear in the rules.
+type variantMatcher struct {
+	dimensions []string
+	rules      []VariantRule
+	regexes    []*regexp.Regexp
+}
+
+// ownerMatcher looks up the owner rules of exact test names before trying the patterns in order.
+type ownerMatcher struct {
+	tests    map[string]OwnerRule
+	patterns []OwnerRule
+	regexes  []*regexp.Regexp
+}
+
+// Rules are the compiled ignored tests, variant rules and owner rules.  They are compiled and replaced as a whole,
+// so a report is never analyzed or rendered with a mix of old and new rules.
+type Rules struct {
+	ignoreTests *regexp.Regexp
+	variants    *variantMatcher
+	owners      *ownerMatcher
+}
+
+func init() {
+	rules, err := CompileRules(DefaultIgnoreTests, DefaultVariantRules, nil)
+	if err != nil {
+		panic(err)
+	}
+	SetRules(rules)
+}
+
+// CompileRules checks and compiles the regexes of the ignored tests, the variant rules and the owner rules.
+func CompileRules(ignoreTests []string, variants []VariantRule, owners []OwnerRule) (*Rules, error) {
+	ignoreTestRegex, err := compileIgnoreTests(ignoreTests)
+	if err != nil {
+		return nil, err
+	}
+	variantMatcher, err := compileVariantRules(variants)
+	if err != nil {
+		return nil, err
+	}
+	ownerMatcher, err := compileOwnerRules(owners)
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{ignoreTests: ignoreTestRegex, variants: variantMatcher, owners: ownerMatcher}, nil
+}
+
+// SetRules replaces the rules used by VariantDimensions, and by analyses that weren't given rules of their own.  The
+// server sets them along with the reports that were analyzed with them.
+func SetRules(rules *Rules) {
+	currentRules.Store(rules)
+}
+
+// CurrentRules are the rules last passed to SetRules.
+func CurrentRules() *Rules {
+	return currentRules.Load().(*Rules)
+}
+
+func compileIgnoreTests(patterns []string) (*regexp.Regexp, error) {
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid ignored test pattern %q: %v", pattern, err)
+		}
+	}
+	if len(patterns) == 0 {
+		// matches nothing
+		return regexp.MustCompile(`$^`), nil
+	}
+	return regexp.MustCompile("(?:" + strings.Join(patterns, ")|(?:") + ")"), nil
+}
+
+// IgnoreTest returns true for tests that are left out of the failing and flaky test lists.
+func (r *Rules) IgnoreTest(name string) bool {
+	return r.ignoreTests.MatchString(name)
+}
+
+func compileVariantRules(rules []VariantRule) (*variantMatcher, error) {
+	matcher := &variantMatcher{}
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if len(rule.Dimension) == 0 || len(rule.Value) == 0 {
+			return nil, fmt.Errorf("variant rule for %q needs a dimension and a value", rule.Pattern)
+		}
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for variant %s: %v", VariantName(rule.Dimension, rule.Value), err)
+		}
+		if !seen[rule.Dimension] {
+			seen[rule.Dimension] = true
+			matcher.dimensions = append(matcher.dimensions, rule.Dimension)
+		}
+		matcher.rules = append(matcher.rules, rule)
+		matcher.regexes = append(matcher.regexes, regex)
+	}
+	return matcher, nil
+}
+
+// VariantDimensions are the dimensions of the variant rules, in the order they first appear in the rules.
+func (r *Rules) VariantDimensions() []string {
+	return r.variants.dimensions
+}
+
+// VariantDimensions are the dimensions of the current rules, see Rules.VariantDimensions.
+func VariantDimensions() []string {
+	return CurrentRules().VariantDimensions()
+}
+
+// FindVariant parses the job name into the value of each dimension.  A dimension none of whose rules match the job
+// is left out of the variant.
+func (r *Rules) FindVariant(name string) Variant {
+	variant := Variant{}
+	for i, rule := range r.variants.rules {
+		if _, ok := variant[rule.Dimension]; ok {
+			continue
+		}
+		if r.variants.regexes[i].MatchString(name) {
+			variant[rule.Dimension] = rule.Value
+		}
+	}
+	return variant
+}
+
+// String lists the values of the variant in the order of the dimensions of the variant rules, e.g.
+// cloud=aws, arch=amd64.
+func (v Variant) String() string {
+	names := []string{}
+	for _, dimension := range VariantDimensions() {
+		if value, ok := v[dimension]; ok {
+			names = append(names, VariantName(dimension, value))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// VariantName labels the value of a dimension, e.g. cloud=aws.
+func VariantName(dimension, value string) string {
+	return dimension + "=" + value
+}
+
+func compileOwnerRules(rules []OwnerRule) (*ownerMatcher, error) {
+	matcher := &ownerMatcher{tests: make(map[string]OwnerRule)}
+	for _, rule := range rules {
+		if (len(rule.Test) == 0) == (len(rule.Pattern) == 0) {
+			return nil, fmt.Errorf("owner rule for team %q needs either a test or a pattern", rule.Team)
+		}
+		if len(rule.Sig) == 0 && len(rule.Component) == 0 && len(rule.Team) == 0 {
+			return nil, fmt.Errorf("owner rule for %q needs a sig, component or team", rule.Test+rule.Pattern)
+		}
+		if len(rule.Test) > 0 {
+			if _, ok := matcher.tests[rule.Test]; ok {
+				return nil, fmt.Errorf("duplicate owner rule for test %q", rule.Test)
+			}
+			matcher.tests[rule.Test] = rule
+			continue
+		}
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid owner pattern %q: %v", rule.Pattern, err)
+		}
+		matcher.patterns = append(matcher.patterns, rule)
+		matcher.regexes = append(matcher.regexes, regex)
+	}
+	return matcher, nil
+}
+
+// FindOwner returns the owner of the test from the rule for its exact name, or else the first rule whose pattern
+// matches it.  The sig falls back to the [sig-...] tag of the test name, see FindSig.
+func (r *Rules) FindOwner(name string) Owner {
+	rule, ok := r.owners.tests[name]
+	for i := 0; i < len(r.owners.patterns) && !ok; i++ {
+		if r.owners.regexes[i].MatchString(name) {
+			rule, ok = r.owners.patterns[i], true
+		}
+	}
+	owner := Owner{Sig: rule.Sig, Component: rule.Component, Team: rule.Team}
+	if len(owner.Sig) == 0 {
+		owner.Sig = FindSig(name)
+	}
+	if len(owner.Component) == 0 {
+		owner.Component = UnknownComponent
+	}
+	if len(owner.Team) == 0 {
+		owner.Team = UnknownTeam
+	}
+	return owner
+}
@@ -0,0 +1,174 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindVariant(t *testing.T) {
+	defaults, err := CompileRules(DefaultIgnoreTests, DefaultVariantRules, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := CompileRules(nil, []VariantRule{
+		{Dimension: "cloud", Value: "aws", Pattern: `-aws-`},
+		{Dimension: "cloud", Value: "aws-ovn", Pattern: `-aws-ovn-`},
+		{Dimension: "network", Value: "ovn", Pattern: `-ovn-`},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// variant is the default variant with the given values.
+	variant := func(values Variant) Variant {
+		v := Variant{"cloud": "unknown", "arch": "amd64", "network": "sdn", "topology": "ha", "install": "ipi",
+			"upgrade": "none", "suite": "parallel"}
+		for dimension, value := range values {
+			v[dimension] = value
+		}
+		return v
+	}
+
+	tests := []struct {
+		name    string
+		rules   *Rules
+		job     string
+		variant Variant
+	}{
+		{
+			name:    "default values",
+			rules:   defaults,
+			job:     "release-openshift-ocp-installer-e2e-aws-4.4",
+			variant: variant(Variant{"cloud": "aws"}),
+		},
+		{
+			name:    "arch at the end of the name",
+			rules:   defaults,
+			job:     "promote-release-openshift-machine-os-content-e2e-aws-4.4-ppc64le",
+			variant: variant(Variant{"cloud": "aws", "arch": "ppc64le"}),
+		},
+		{
+			name:    "s390x at the end of the name",
+			rules:   defaults,
+			job:     "promote-release-openshift-machine-os-content-e2e-aws-4.4-s390x",
+			variant: variant(Variant{"cloud": "aws", "arch": "s390x"}),
+		},
+		{
+			name:    "network",
+			rules:   defaults,
+			job:     "release-openshift-origin-installer-e2e-aws-ovn-network-stress-4.4",
+			variant: variant(Variant{"cloud": "aws", "network": "ovn"}),
+		},
+		{
+			name:    "topology",
+			rules:   defaults,
+			job:     "release-openshift-origin-installer-e2e-gcp-compact-4.4",
+			variant: variant(Variant{"cloud": "gcp", "topology": "compact"}),
+		},
+		{
+			name:    "install and suite",
+			rules:   defaults,
+			job:     "release-openshift-ocp-installer-e2e-vsphere-upi-serial-4.4",
+			variant: variant(Variant{"cloud": "vsphere", "install": "upi", "suite": "serial"}),
+		},
+		{
+			name:    "upgrade",
+			rules:   defaults,
+			job:     "release-openshift-origin-installer-e2e-aws-upgrade-fips-4.4",
+			variant: variant(Variant{"cloud": "aws", "install": "fips", "upgrade": "upgrade"}),
+		},
+		{
+			name:    "first matching rule wins",
+			rules:   custom,
+			job:     "release-openshift-origin-installer-e2e-aws-ovn-network-stress-4.4",
+			variant: Variant{"cloud": "aws", "network": "ovn"},
+		},
+		{
+			name:    "unmatched dimensions are left out",
+			rules:   custom,
+			job:     "release-openshift-origin-installer-e2e-gcp-4.4",
+			variant: Variant{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if variant := tt.rules.FindVariant(tt.job); !reflect.DeepEqual(variant, tt.variant) {
+				t.Errorf("expected variant %v, got %v", tt.variant, variant)
+			}
+		})
+	}
+}
+
+func TestFindOwner(t *testing.T) {
+	rules, err := CompileRules(nil, nil, []OwnerRule{
+		{Test: "[sig-network] services should serve", Component: "networking", Team: "network-edge"},
+		{Test: "operator install console", Sig: "sig-ui", Team: "console"},
+		{Pattern: `^operator `, Sig: "sig-arch", Component: "operators"},
+		{Pattern: `^operator install`, Team: "installer"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		test  string
+		owner Owner
+	}{
+		{
+			name:  "exact test keeps the sig tag",
+			test:  "[sig-network] services should serve",
+			owner: Owner{Sig: "sig-network", Component: "networking", Team: "network-edge"},
+		},
+		{
+			name:  "exact test before the patterns",
+			test:  "operator install console",
+			owner: Owner{Sig: "sig-ui", Component: UnknownComponent, Team: "console"},
+		},
+		{
+			name:  "first matching pattern",
+			test:  "operator install authentication",
+			owner: Owner{Sig: "sig-arch", Component: "operators", Team: UnknownTeam},
+		},
+		{
+			name:  "no rule with a sig tag",
+			test:  "[sig-storage] volumes should mount",
+			owner: Owner{Sig: "sig-storage", Component: UnknownComponent, Team: UnknownTeam},
+		},
+		{
+			name:  "no rule without a sig tag",
+			test:  "Cluster upgrade should complete",
+			owner: Owner{Sig: "sig-unknown", Component: UnknownComponent, Team: UnknownTeam},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if owner := rules.FindOwner(tt.test); owner != tt.owner {
+				t.Errorf("expected owner %+v, got %+v", tt.owner, owner)
+			}
+		})
+	}
+}
+
+func TestCompileRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		ignore   []string
+		variants []VariantRule
+		owners   []OwnerRule
+	}{
+		{name: "invalid ignored test", ignore: []string{"("}},
+		{name: "variant rule without a dimension", variants: []VariantRule{{Value: "aws", Pattern: "-aws-"}}},
+		{name: "invalid variant pattern", variants: []VariantRule{{Dimension: "cloud", Value: "aws", Pattern: "("}}},
+		{name: "owner rule without a test or pattern", owners: []OwnerRule{{Team: "console"}}},
+		{name: "owner rule with a test and a pattern", owners: []OwnerRule{{Test: "a", Pattern: "b", Team: "console"}}},
+		{name: "owner rule without an owner", owners: []OwnerRule{{Test: "a"}}},
+		{name: "duplicate owner rule", owners: []OwnerRule{{Test: "a", Team: "console"}, {Test: "a", Team: "installer"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CompileRules(tt.ignore, tt.variants, tt.owners); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"math"
+)
+
+const (
+	// SignificanceLevel is the p-value below which a change in pass rate is treated as real rather than noise.
+	SignificanceLevel = 0.05
+
+	// z95 is the two-sided critical value for a 95% confidence interval.
+	z95 = 1.959964
+
+	// fisherThreshold is the expected count below which the normal approximation is unreliable and Fisher's
+	// exact test is used instead.
+	fisherThreshold = 5
+)
+
+// Significance describes the change between two pass rates.  Delta and the confidence interval are in
+// percentage points, current minus previous.
+type Significance struct {
+	Delta       float64 `json:"delta"`
+	Low         float64 `json:"low"`
+	High        float64 `json:"high"`
+	PValue      float64 `json:"pValue"`
+	Significant bool    `json:"significant"`
+	// Exact is true when the p-value came from Fisher's exact test rather than the z-test.
+	Exact bool `json:"exact"`
+}
+
+// ComparePassRates tests whether the current pass rate differs from the previous one, using a two-proportion z-test,
+// or Fisher's exact test when the samples are too small for the z-test.
+func ComparePassRates(successes, failures, prevSuccesses, prevFailures int) Significance {
+	n1 := float64(successes + failures)
+	n2 := float64(prevSuccesses + prevFailures)
+	if n1 == 0 || n2 == 0 {
+		return Significance{PValue: 1}
+	}
+	p1 := float64(successes) / n1
+	p2 := float64(prevSuccesses) / n2
+
+	// unpooled standard error for the confidence interval of the difference
+	se := math.Sqrt(p1*(1-p1)/n1 + p2*(1-p2)/n2)
+	s := Significance{
+		Delta: (p1 - p2) * 100,
+		Low:   (p1 - p2 - z95*se) * 100,
+		High:  (p1 - p2 + z95*se) * 100,
+	}
+
+	pooled := float64(successes+prevSuccesses) / (n1 + n2)
+	small := false
+	for _, expected := range []float64{pooled * n1, (1 - pooled) * n1, pooled * n2, (1 - pooled) * n2} {
+		if expected < fisherThreshold {
+			small = true
+		}
+	}
+
+	switch {
+	case small:
+		s.PValue = fisherExact(successes, failures, prevSuccesses, prevFailures)
+		s.Exact = true
+	case pooled == 0 || pooled == 1:
+		s.PValue = 1
+	default:
+		z := (p1 - p2) / math.Sqrt(pooled*(1-pooled)*(1/n1+1/n2))
+		s.PValue = math.Erfc(math.Abs(z) / math.Sqrt2)
+	}
+	s.Significant = s.PValue < SignificanceLevel
+	return s
+}
+
+// fisherExact is the two-sided p-value of Fisher's exact test for the 2x2 table [[a b] [c d]].
+func fisherExact(a, b, c, d int) float64 {
+	row1, col1, n := a+b, a+c, a+b+c+d
+	observed := hypergeometric(a, row1, col1, n)
+
+	min := 0
+	if col1-(n-row1) > min {
+		min = col1 - (n - row1)
+	}
+	max := row1
+	if col1 < max {
+		max = col1
+	}
+
+	// sum the probability of every table with the same margins that is no more likely than the observed one
+	p := 0.0
+	for x := min; x <= max; x++ {
+		if px := hypergeometric(x, row1, col1, n); px <= observed*(1+1e-7) {
+			p += px
+		}
+	}
+	if p > 1 {
+		p = 1
+	}
+	return p
+}
+
+// hypergeometric is the probability of x successes in the first row of a 2x2 table with the given margins.
+func hypergeometric(x, row1, col1, n int) float64 {
+	return math.Exp(logChoose(row1, x) + logChoose(n-row1, col1-x) - logChoose(n, col1))
+}
+
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComparePassRates(t *testing.T) {
+	tests := []struct {
+		name                                             string
+		successes, failures, prevSuccesses, prevFailures int
+		delta                                            float64
+		significant                                      bool
+		exact                                            bool
+	}{
+		{name: "no current runs", prevSuccesses: 10, prevFailures: 1},
+		{name: "no previous runs", successes: 10, failures: 1},
+		{name: "unchanged", successes: 90, failures: 10, prevSuccesses: 90, prevFailures: 10},
+		{name: "small change", successes: 88, failures: 12, prevSuccesses: 90, prevFailures: 10, delta: -2},
+		{name: "large drop", successes: 60, failures: 40, prevSuccesses: 90, prevFailures: 10, delta: -30, significant: true},
+		{name: "large rise", successes: 90, failures: 10, prevSuccesses: 60, prevFailures: 40, delta: 30, significant: true},
+		{name: "few runs", successes: 1, failures: 2, prevSuccesses: 3, delta: -200.0 / 3, exact: true},
+		{name: "all failing after all passing", failures: 10, prevSuccesses: 10, delta: -100, significant: true},
+		{name: "few failing after all passing", failures: 4, prevSuccesses: 4, delta: -100, significant: true, exact: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ComparePassRates(tt.successes, tt.failures, tt.prevSuccesses, tt.prevFailures)
+			if math.Abs(s.Delta-tt.delta) > 1e-9 {
+				t.Errorf("expected delta %g, got %g", tt.delta, s.Delta)
+			}
+			if s.Significant != tt.significant {
+				t.Errorf("expected significant %t, got %t with p-value %g", tt.significant, s.Significant, s.PValue)
+			}
+			if s.Exact != tt.exact {
+				t.Errorf("expected exact %t, got %t", tt.exact, s.Exact)
+			}
+			if s.Low > s.Delta || s.High < s.Delta {
+				t.Errorf("delta %g is outside of the confidence interval %g to %g", s.Delta, s.Low, s.High)
+			}
+		})
+	}
+}
+
+func TestFisherExact(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b, c, d int
+		p          float64
+	}{
+		// the lady tasting tea
+		{name: "tea", a: 3, b: 1, c: 1, d: 3, p: 34.0 / 70},
+		{name: "symmetric", a: 1, b: 2, c: 3, d: 0, p: 0.4},
+		{name: "opposite", a: 0, b: 10, c: 10, d: 0, p: 2.0 / 184756},
+		{name: "identical", a: 5, b: 5, c: 5, d: 5, p: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if p := fisherExact(tt.a, tt.b, tt.c, tt.d); math.Abs(p-tt.p) > 1e-9 {
+				t.Errorf("expected p-value %g, got %g", tt.p, p)
+			}
+		})
+	}
+}
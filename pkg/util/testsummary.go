@@ -0,0 +1,140 @@
+package util
+
+import (
+	"sort"
+	"time"
+)
+
+// TestSummary breaks the pass rate of a single test down by release, job and variant, and lists its runs.
+type TestSummary struct {
+	Name    string `json:"name"`
+	Release string `json:"release"`
+	// Sig, Component and Team own the test, see FindOwner.
+	Sig       string   `json:"sig"`
+	Component string   `json:"component"`
+	Team      string   `json:"team"`
+	BugList   []string `json:"bugList"`
+	// PassRate is the pass rate of the test across every job in the release.
+	PassRate TestBreakdown      `json:"passRate"`
+	Releases []TestBreakdown    `json:"releases"`
+	Jobs     []TestBreakdown    `json:"jobs"`
+	Variants []VariantBreakdown `json:"variants"`
+	// Runs are the job runs of the test, newest first.
+	Runs []TestRun `json:"runs"`
+}
+
+// TestBreakdown is the pass rate of a test within one release, job or variant value.
+type TestBreakdown struct {
+	Name string `json:"name"`
+	PassRate
+	Flakes int `json:"flakes"`
+}
+
+// VariantBreakdown is the pass rate of a test for each value of a dimension of the job variants.
+type VariantBreakdown struct {
+	Dimension string          `json:"dimension"`
+	Values    []TestBreakdown `json:"values"`
+}
+
+// TestRun is the result of a test in a single job run.
+type TestRun struct {
+	Job       string    `json:"job"`
+	Url       string    `json:"url"`
+	Timestamp time.Time `json:"timestamp"`
+	Failed    bool      `json:"failed"`
+}
+
+// NewTestBreakdown is the pass rate of the test result, labelled with the name of the release, job or variant value
+// it is from.
+func NewTestBreakdown(name string, result TestResult) TestBreakdown {
+	return TestBreakdown{
+		Name:     name,
+		PassRate: NewPassRate(result.Successes, result.Failures),
+		Flakes:   result.Flakes,
+	}
+}
+
+// breakdown lists the results of the test in each category that has any, lowest pass rate first.
+func breakdown(name string, categories map[string]AggregateTestResult) []TestBreakdown {
+	breakdowns := []TestBreakdown{}
+	for category, results := range categories {
+		if result, ok := results.TestResults[name]; ok && result.Successes+result.Failures > 0 {
+			breakdowns = append(breakdowns, NewTestBreakdown(category, result))
+		}
+	}
+	sort.SliceStable(breakdowns, func(i, j int) bool {
+		a, b := breakdowns[i], breakdowns[j]
+		if a.PassPercentage != b.PassPercentage {
+			return a.PassPercentage < b.PassPercentage
+		}
+		return a.Name < b.Name
+	})
+	return breakdowns
+}
+
+// variantBreakdown breaks the results of the test down by the value of each dimension, in the order of the variant
+// rules.  Dimensions the test has no results in are left out.
+func variantBreakdown(name string, byVariant map[string]map[string]AggregateTestResult, rules *Rules) []VariantBreakdown {
+	breakdowns := []VariantBreakdown{}
+	for _, dimension := range rules.VariantDimensions() {
+		if values := breakdown(name, byVariant[dimension]); len(values) > 0 {
+			breakdowns = append(breakdowns, VariantBreakdown{Dimension: dimension, Values: values})
+		}
+	}
+	return breakdowns
+}
+
+// SummarizeTest breaks the results of the test in the release down by job and the variant dimensions of the rules
+// it was analyzed with, and lists its most recent runCount runs from the job runs of the release.  It returns false
+// if the test has no results in the release.
+func SummarizeTest(name, release string, byAll, byJob map[string]AggregateTestResult, byVariant map[string]map[string]AggregateTestResult, runs map[string]JobRunResult, rules *Rules, runCount int) (TestSummary, bool) {
+	result, ok := byAll["all"].TestResults[name]
+	if !ok {
+		return TestSummary{}, false
+	}
+	summary := TestSummary{
+		Name:      name,
+		Release:   release,
+		Sig:       result.Owner.Sig,
+		Component: result.Owner.Component,
+		Team:      result.Owner.Team,
+		BugList:   result.BugList,
+		PassRate:  NewTestBreakdown(release, result),
+		Releases:  []TestBreakdown{},
+		Jobs:      breakdown(name, byJob),
+		Variants:  variantBreakdown(name, byVariant, rules),
+		Runs:      []TestRun{},
+	}
+	if summary.BugList == nil {
+		summary.BugList = []string{}
+	}
+
+	// go through the runs newest first, so only the runs that make it into the timeline are searched
+	sorted := make([]JobRunResult, 0, len(runs))
+	for _, run := range runs {
+		sorted = append(sorted, run)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.Url < b.Url
+	})
+	for _, run := range sorted {
+		if len(summary.Runs) >= runCount {
+			break
+		}
+		if containsName(run.TestNames, name) {
+			failed := containsName(run.FailedTestNames, name)
+			summary.Runs = append(summary.Runs, TestRun{Job: run.Job, Url: run.Url, Timestamp: run.Timestamp, Failed: failed})
+		}
+	}
+	return summary, true
+}
+
+// containsName looks the test up in the sorted test names of a job run.
+func containsName(names []string, name string) bool {
+	i := sort.SearchStrings(names, name)
+	return i < len(names) && names[i] == name
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"time"
+)
+
+// TrendPoint is the pass rate over one bucket of days, counted back from the time of the analysis.
+type TrendPoint struct {
+	StartDay       int     `json:"startDay"`
+	EndDay         int     `json:"endDay"`
+	Successes      int     `json:"successes"`
+	Failures       int     `json:"failures"`
+	PassPercentage float64 `json:"passPercentage"`
+}
+
+// Trend is a pass rate series, oldest bucket first.
+type Trend []TrendPoint
+
+// Trends holds a pass rate series for each test, job, sig and value of each variant dimension.  Test and sig
+// series count test runs, job and variant series count job runs.  Variants are keyed by dimension, then value.
+type Trends struct {
+	StartDay   int                         `json:"startDay"`
+	EndDay     int                         `json:"endDay"`
+	BucketDays int                         `json:"bucketDays"`
+	Tests      map[string]Trend            `json:"tests"`
+	Jobs       map[string]Trend            `json:"jobs"`
+	Variants   map[string]map[string]Trend `json:"variants"`
+	Sigs       map[string]Trend            `json:"sigs"`
+}
+
+func NewTrends(startDay, endDay, bucketDays int) Trends {
+	if bucketDays < 1 {
+		bucketDays = 1
+	}
+	return Trends{
+		StartDay:   startDay,
+		EndDay:     endDay,
+		BucketDays: bucketDays,
+		Tests:      make(map[string]Trend),
+		Jobs:       make(map[string]Trend),
+		Variants:   make(map[string]map[string]Trend),
+		Sigs:       make(map[string]Trend),
+	}
+}
+
+func (t Trends) buckets() int {
+	return (t.EndDay - t.StartDay + t.BucketDays - 1) / t.BucketDays
+}
+
+func (t Trends) newTrend() Trend {
+	n := t.buckets()
+	trend := make(Trend, n)
+	for i := range trend {
+		// the last bucket is the most recent
+		trend[i].StartDay = t.StartDay + (n-1-i)*t.BucketDays
+		trend[i].EndDay = trend[i].StartDay + t.BucketDays
+		if trend[i].EndDay > t.EndDay {
+			trend[i].EndDay = t.EndDay
+		}
+	}
+	return trend
+}
+
+// Add records a run of the named series that happened age before the time of the analysis.  Runs outside the
+// window are ignored.
+func (t Trends) Add(series map[string]Trend, name string, age time.Duration, passed bool) {
+	day := int(age / (24 * time.Hour))
+	if day < t.StartDay || day >= t.EndDay {
+		return
+	}
+	trend, ok := series[name]
+	if !ok {
+		trend = t.newTrend()
+		series[name] = trend
+	}
+	i := len(trend) - 1 - (day-t.StartDay)/t.BucketDays
+	if passed {
+		trend[i].Successes++
+	} else {
+		trend[i].Failures++
+	}
+}
+
+// AddVariant records a run of a job with the variant in the series of each of its dimensions.
+func (t Trends) AddVariant(variant Variant, age time.Duration, passed bool) {
+	for dimension, value := range variant {
+		series, ok := t.Variants[dimension]
+		if !ok {
+			series = make(map[string]Trend)
+			t.Variants[dimension] = series
+		}
+		t.Add(series, value, age, passed)
+	}
+}
+
+// ComputePercentages fills in the pass percentage of every point.
+func (t Trends) ComputePercentages() {
+	all := []map[string]Trend{t.Tests, t.Jobs, t.Sigs}
+	for _, series := range t.Variants {
+		all = append(all, series)
+	}
+	for _, series := range all {
+		for _, trend := range series {
+			for i := range trend {
+				trend[i].PassPercentage = Percent(trend[i].Successes, trend[i].Failures)
+			}
+		}
+	}
+}
+
+// Series returns the series of the given kind: tests, jobs, sigs or the name of a variant dimension.
+func (t Trends) Series(kind string) (map[string]Trend, bool) {
+	switch kind {
+	case "tests":
+		return t.Tests, true
+	case "jobs":
+		return t.Jobs, true
+	case "sigs":
+		return t.Sigs, true
+	}
+	series, ok := t.Variants[kind]
+	return series, ok
+}
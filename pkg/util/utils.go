@@ -13,53 +13,53 @@ import (
 	"time"
 
 	"k8s.io/klog"
+
+	"github.com/bparees/sippy/pkg/knownissues"
 )
 
 var (
 	sigRegex      *regexp.Regexp = regexp.MustCompile(`\[(sig-.*?)\]`)
 	bugzillaRegex *regexp.Regexp = regexp.MustCompile(`(https://bugzilla.redhat.com/show_bug.cgi\?id=\d+)`)
-
-	// platform regexes
-	awsRegex       *regexp.Regexp = regexp.MustCompile(`(?i)-aws-`)
-	azureRegex     *regexp.Regexp = regexp.MustCompile(`(?i)-azure-`)
-	gcpRegex       *regexp.Regexp = regexp.MustCompile(`(?i)-gcp-`)
-	openstackRegex *regexp.Regexp = regexp.MustCompile(`(?i)-openstack-`)
-	metalRegex     *regexp.Regexp = regexp.MustCompile(`(?i)-metal-`)
-	ovirtRegex     *regexp.Regexp = regexp.MustCompile(`(?i)-ovirt-`)
-	vsphereRegex   *regexp.Regexp = regexp.MustCompile(`(?i)-vsphere-`)
-	upgradeRegex   *regexp.Regexp = regexp.MustCompile(`(?i)-upgrade-`)
-
-	// ignored for top 10 failing test reporting only.
-	IgnoreTestRegex *regexp.Regexp = regexp.MustCompile(`operator.Run template|Monitor cluster while tests execute|Overall|job.initialize`)
-	// Tests we are already tracking an issue for
-//	KnownIssueTestRegex *regexp.Regexp = regexp.MustCompile(`Application behind service load balancer with PDB is not disrupted|Kubernetes and OpenShift APIs remain available|Cluster frontend ingress remain available|OpenShift APIs remain available|Kubernetes APIs remain available|Cluster upgrade should maintain a functioning cluster`)
 )
 
 type TestMeta struct {
 	Name    string
 	Count   int
 	Jobs    map[string]interface{}
-	Sig     string
+	Owner   Owner
 	BugList []string
 	BugErr  error
 }
 
 type TestReport struct {
-	Release                   string                               `json:"release"`
-	All                       map[string]SortedAggregateTestResult `json:"all"`
-	ByPlatform                map[string]SortedAggregateTestResult `json:"byPlatform`
-	ByJob                     map[string]SortedAggregateTestResult `json:"byJob`
-	BySig                     map[string]SortedAggregateTestResult `json:"bySig`
-	FailureGroups             []JobRunResult                       `json:"failureGroups"`
-	JobPassRate               []JobResult                          `json:"jobPassRate"`
-	Timestamp                 time.Time                            `json:"timestamp"`
-	TopFailingTestsWithBug    []*TestResult                        `json:"topFailingTestsWithBug"`
-	TopFailingTestsWithoutBug []*TestResult                        `json:"topFailingTestsWithoutBug"`
+	Release                   string                                          `json:"release"`
+	All                       map[string]SortedAggregateTestResult            `json:"all"`
+	ByVariant                 map[string]map[string]SortedAggregateTestResult `json:"byVariant"`
+	ByJob                     map[string]SortedAggregateTestResult            `json:"ByJob"`
+	BySig                     map[string]SortedAggregateTestResult            `json:"BySig"`
+	ByComponent               map[string]SortedAggregateTestResult            `json:"byComponent"`
+	ByTeam                    map[string]SortedAggregateTestResult            `json:"byTeam"`
+	FailureGroups             []JobRunResult                                  `json:"failureGroups"`
+	JobPassRate               []JobResult                                     `json:"jobPassRate"`
+	Timestamp                 time.Time                                       `json:"timestamp"`
+	TopFailingTestsWithBug    []*TestResult                                   `json:"topFailingTestsWithBug"`
+	TopFailingTestsWithoutBug []*TestResult                                   `json:"topFailingTestsWithoutBug"`
+	Flakes                    []*TestResult                                   `json:"flakes"`
+	// TopFailingTestsWithKnownIssue are failing tests that match a known issue, they are left out of the other lists.
+	// KnownIssues is the status of every known issue, flagging the ones whose bug was closed or that expired.
+	TopFailingTestsWithKnownIssue []*TestResult        `json:"topFailingTestsWithKnownIssue"`
+	KnownIssues                   []knownissues.Status `json:"knownIssues"`
+	// StartDay and EndDay are the window of days the report covers, counted back from the time of the analysis, or
+	// from the newest run of a baseline.
+	StartDay int `json:"startDay"`
+	EndDay   int `json:"endDay"`
 }
 
 type SortedAggregateTestResult struct {
 	Successes          int          `json:"successes"`
 	Failures           int          `json:"failures"`
+	Flakes             int          `json:"flakes"`
+	Timeouts           int          `json:"timeouts"`
 	TestPassPercentage float64      `json:"testPassPercentage"`
 	TestResults        []TestResult `json:"results"`
 }
@@ -67,33 +67,67 @@ type SortedAggregateTestResult struct {
 type AggregateTestResult struct {
 	Successes          int                   `json:"successes"`
 	Failures           int                   `json:"failures"`
+	Flakes             int                   `json:"flakes"`
+	Timeouts           int                   `json:"timeouts"`
 	TestPassPercentage float64               `json:"testPassPercentage"`
 	TestResults        map[string]TestResult `json:"results"`
 }
 
+// TestCounts are the results of a test in a single job.  Flaky runs are also counted as successes and timed out
+// runs are also counted as failures.  Flips are failed runs where the next run of the job passed.
+type TestCounts struct {
+	Successes int
+	Failures  int
+	Flakes    int
+	Timeouts  int
+	Flips     int
+}
+
 type TestResult struct {
-	Name           string   `json:"name"`
-	Successes      int      `json:"successes"`
-	Failures       int      `json:"failures"`
-	PassPercentage float64  `json:"passPercentage"`
-	BugList        []string `json:"BugList"`
-	BugErr         error    `json:"BugErr"`
-	SearchLink     string   `json:"searchLink"`
+	Name            string   `json:"name"`
+	Successes       int      `json:"successes"`
+	Failures        int      `json:"failures"`
+	Flakes          int      `json:"flakes"`
+	Timeouts        int      `json:"timeouts"`
+	Flips           int      `json:"flips"`
+	PassPercentage  float64  `json:"passPercentage"`
+	FlakePercentage float64  `json:"flakePercentage"`
+	BugList         []string `json:"BugList"`
+	BugErr          error    `json:"BugErr"`
+	SearchLink      string   `json:"searchLink"`
+	Owner           Owner    `json:"owner"`
+	// KnownIssue is the known issue the failures of the test are tracked by, if any.
+	KnownIssue *knownissues.Issue `json:"knownIssue,omitempty"`
 }
 
+// JobRunResult is a single run of a job.  Variant is the variant of the job found by the rules of the analysis.
+// TestNames are all the tests with a result in the run, FailedTestNames the ones that failed, both sorted by name
+// once the analysis is done so tests can be looked up in them.
 type JobRunResult struct {
-	Job            string   `json:"job"`
-	Url            string   `json:"url"`
-	TestGridJobUrl string   `json:"url"`
-	TestFailures   int      `json:"testFailures"`
-	TestNames      []string `json:"testNames"`
-	Failed         bool     `json:"failed"`
-	Succeeded      bool     `json:"succeeded"`
+	Job             string    `json:"job"`
+	Url             string    `json:"url"`
+	TestGridJobUrl  string    `json:"testGridJobUrl"`
+	DashboardType   string    `json:"dashboardType"`
+	Variant         Variant   `json:"variant"`
+	Timestamp       time.Time `json:"timestamp"`
+	TestFailures    int       `json:"testFailures"`
+	TestNames       []string  `json:"testNames"`
+	FailedTestNames []string  `json:"failedTestNames"`
+	Failed          bool      `json:"failed"`
+	Succeeded       bool      `json:"succeeded"`
 }
 
+// JobResult is the pass rate of a job, or of all the jobs with the same value of a dimension of their variant, e.g.
+// cloud=aws.  DashboardType is the type of the dashboard the job is on, e.g. blocking or informing.  JobVariant is
+// the variant of a single job, Dimension and Variant the value of the jobs that were aggregated.  Platform repeats
+// the cloud value for the clients of /api/v1/platforms.
 type JobResult struct {
 	Name           string  `json:"name"`
-	Platform       string  `json:"platform"`
+	DashboardType  string  `json:"dashboardType,omitempty"`
+	JobVariant     Variant `json:"jobVariant,omitempty"`
+	Dimension      string  `json:"dimension,omitempty"`
+	Variant        string  `json:"variant,omitempty"`
+	Platform       string  `json:"platform,omitempty"`
 	Failures       int     `json:"failures"`
 	Successes      int     `json:"successes"`
 	PassPercentage float64 `json:"PassPercentage"`
@@ -115,11 +149,21 @@ func Percent(success, failure int) float64 {
 	return float64(success) / float64(success+failure) * 100.0
 }
 
+// FlakePercent is the percentage of the test's runs that either flaked, or failed and then passed on the next run.
+// A test that is consistently broken has a low flake percentage, one that fails intermittently has a high one.
+func FlakePercent(r TestResult) float64 {
+	if r.Successes+r.Failures == 0 {
+		return 0.0
+	}
+	return float64(r.Flakes+r.Flips) / float64(r.Successes+r.Failures) * 100.0
+}
+
 func ComputePercentages(AggregateTestResults map[string]AggregateTestResult) {
 	for k, AggregateTestResult := range AggregateTestResults {
 		AggregateTestResult.TestPassPercentage = Percent(AggregateTestResult.Successes, AggregateTestResult.Failures)
 		for k2, r := range AggregateTestResult.TestResults {
 			r.PassPercentage = Percent(r.Successes, r.Failures)
+			r.FlakePercentage = FlakePercent(r)
 			AggregateTestResult.TestResults[k2] = r
 		}
 		AggregateTestResults[k] = AggregateTestResult
@@ -133,6 +177,8 @@ func GenerateSortedResults(AggregateTestResult map[string]AggregateTestResult, m
 		sorted[k] = SortedAggregateTestResult{
 			Failures:           v.Failures,
 			Successes:          v.Successes,
+			Flakes:             v.Flakes,
+			Timeouts:           v.Timeouts,
 			TestPassPercentage: v.TestPassPercentage,
 		}
 
@@ -154,6 +200,29 @@ func GenerateSortedResults(AggregateTestResult map[string]AggregateTestResult, m
 	return sorted
 }
 
+// TopFlakyTests returns up to count tests with the highest flake percentage, ignoring tests with fewer than minRuns runs.
+func TopFlakyTests(result AggregateTestResult, rules *Rules, minRuns, count int) []*TestResult {
+	flaky := []*TestResult{}
+	for _, test := range result.TestResults {
+		test := test
+		if test.Flakes+test.Flips == 0 || test.Successes+test.Failures < minRuns || rules.IgnoreTest(test.Name) {
+			continue
+		}
+		flaky = append(flaky, &test)
+	}
+	// sort from highest to lowest
+	sort.SliceStable(flaky, func(i, j int) bool {
+		if flaky[i].FlakePercentage == flaky[j].FlakePercentage {
+			return flaky[i].Name < flaky[j].Name
+		}
+		return flaky[i].FlakePercentage > flaky[j].FlakePercentage
+	})
+	if len(flaky) > count {
+		flaky = flaky[:count]
+	}
+	return flaky
+}
+
 func FilterFailureGroups(jrr map[string]JobRunResult, failureClusterThreshold int) []JobRunResult {
 	filteredJrr := []JobRunResult{}
 	// -1 means don't do this reporting.
@@ -181,8 +250,10 @@ func ComputeJobPassRate(jrr map[string]JobRunResult) []JobResult {
 		job, ok := jobsMap[run.Job]
 		if !ok {
 			job = JobResult{
-				Name:        run.Job,
-				TestGridUrl: run.TestGridJobUrl,
+				Name:          run.Job,
+				DashboardType: run.DashboardType,
+				JobVariant:    run.Variant,
+				TestGridUrl:   run.TestGridJobUrl,
 			}
 		}
 		if run.Failed {
@@ -220,10 +291,12 @@ func RelevantJob(jobName, status string, filter *regexp.Regexp) bool {
 	*/
 }
 
-func ComputeLookback(startday, lookback int, timestamps []int) (int, int) {
+// ComputeLookbackFrom finds the columns of the timestamps, newest first, that are from startday to lookback days
+// before now.  The columns are returned as the first one in the window and the one after the last.
+func ComputeLookbackFrom(now time.Time, startday, lookback int, timestamps []int) (int, int) {
 
-	stopTs := time.Now().Add(time.Duration(-1*lookback*24)*time.Hour).Unix() * 1000
-	startTs := time.Now().Add(time.Duration(-1*startday*24)*time.Hour).Unix() * 1000
+	stopTs := now.Add(time.Duration(-1*lookback*24)*time.Hour).Unix() * 1000
+	startTs := now.Add(time.Duration(-1*startday*24)*time.Hour).Unix() * 1000
 	klog.V(2).Infof("starttime: %d\nendtime: %d\n", startTs, stopTs)
 	start := math.MaxInt32 // start is an int64 so leave overhead for wrapping to negative in case this gets incremented(it does).
 	for i, t := range timestamps {
@@ -246,40 +319,6 @@ func FindSig(name string) string {
 	return "sig-unknown"
 }
 
-func FindPlatform(name string) []string {
-	platforms := []string{}
-	if awsRegex.MatchString(name) {
-		platforms = append(platforms, "aws")
-	}
-	if azureRegex.MatchString(name) {
-		platforms = append(platforms, "azure")
-	}
-	if gcpRegex.MatchString(name) {
-		platforms = append(platforms, "gcp")
-	}
-	if openstackRegex.MatchString(name) {
-		platforms = append(platforms, "openstack")
-	}
-	if metalRegex.MatchString(name) {
-		platforms = append(platforms, "metal")
-	}
-	if ovirtRegex.MatchString(name) {
-		platforms = append(platforms, "ovirt")
-	}
-	if vsphereRegex.MatchString(name) {
-		platforms = append(platforms, "vsphere")
-	}
-	if upgradeRegex.MatchString(name) {
-		platforms = append(platforms, "upgrade")
-	}
-
-	if len(platforms) == 0 {
-		klog.V(2).Infof("unknown platform for job: %s\n", name)
-		return []string{"unknown platform"}
-	}
-	return platforms
-}
-
 func FindBug(testName string) ([]string, error) {
 	testName = regexp.QuoteMeta(testName)
 	klog.V(2).Infof("Searching bugs for test name: %s\n", testName)
@@ -308,9 +347,9 @@ func FindBug(testName string) ([]string, error) {
 	return bugs, nil
 }
 
-func AddTestResult(categoryKey string, categories map[string]AggregateTestResult, testName string, meta TestMeta, passed, failed int) {
+func AddTestResult(categoryKey string, categories map[string]AggregateTestResult, testName string, meta TestMeta, counts TestCounts) {
 
-	klog.V(2).Infof("Adding test %s to category %s, passed: %d, failed: %d\n", testName, categoryKey, passed, failed)
+	klog.V(2).Infof("Adding test %s to category %s, passed: %d, failed: %d, flaked: %d, timed out: %d\n", testName, categoryKey, counts.Successes, counts.Failures, counts.Flakes, counts.Timeouts)
 	category, ok := categories[categoryKey]
 	if !ok {
 		category = AggregateTestResult{
@@ -318,49 +357,58 @@ func AddTestResult(categoryKey string, categories map[string]AggregateTestResult
 		}
 	}
 
-	category.Successes += passed
-	category.Failures += failed
+	category.Successes += counts.Successes
+	category.Failures += counts.Failures
+	category.Flakes += counts.Flakes
+	category.Timeouts += counts.Timeouts
 
 	result, ok := category.TestResults[testName]
 	if !ok {
 		result = TestResult{}
 	}
 	result.Name = testName
-	result.Successes += passed
-	result.Failures += failed
+	result.Successes += counts.Successes
+	result.Failures += counts.Failures
+	result.Flakes += counts.Flakes
+	result.Timeouts += counts.Timeouts
+	result.Flips += counts.Flips
 	result.BugList = meta.BugList
 	result.BugErr = meta.BugErr
+	result.Owner = meta.Owner
 
 	category.TestResults[testName] = result
 
 	categories[categoryKey] = category
 }
 
-func SummarizeJobsByPlatform(report TestReport) []JobResult {
-	jobRunsByPlatform := make(map[string]JobResult)
-	platformResults := []JobResult{}
+// SummarizeJobsByVariant aggregates the job runs by the value of the dimension of the variants the jobs were
+// analyzed with, lowest pass rate first.
+func SummarizeJobsByVariant(report TestReport, dimension string) []JobResult {
+	jobRunsByVariant := make(map[string]JobResult)
+	variantResults := []JobResult{}
 
 	for _, job := range report.JobPassRate {
-		platforms := FindPlatform(job.Name)
-		for _, p := range platforms {
-			j := jobRunsByPlatform[p]
-			j.Successes += job.Successes
-			j.Failures += job.Failures
-			j.Platform = p
-			jobRunsByPlatform[p] = j
+		value, ok := job.JobVariant[dimension]
+		if !ok {
+			continue
 		}
+		j := jobRunsByVariant[value]
+		j.Successes += job.Successes
+		j.Failures += job.Failures
+		j.Dimension = dimension
+		j.Variant = value
+		jobRunsByVariant[value] = j
 	}
 
-	for _, platform := range jobRunsByPlatform {
-
-		platform.PassPercentage = Percent(platform.Successes, platform.Failures)
-		platformResults = append(platformResults, platform)
+	for _, variant := range jobRunsByVariant {
+		variant.PassPercentage = Percent(variant.Successes, variant.Failures)
+		variantResults = append(variantResults, variant)
 	}
 	// sort from lowest to highest
-	sort.SliceStable(platformResults, func(i, j int) bool {
-		return platformResults[i].PassPercentage < platformResults[j].PassPercentage
+	sort.SliceStable(variantResults, func(i, j int) bool {
+		return variantResults[i].PassPercentage < variantResults[j].PassPercentage
 	})
-	return platformResults
+	return variantResults
 }
 
 func SummarizeJobsByName(report TestReport) []JobResult {
@@ -371,6 +419,8 @@ func SummarizeJobsByName(report TestReport) []JobResult {
 		j := jobRunsByName[job.Name]
 		j.Name = job.Name
 		j.TestGridUrl = job.TestGridUrl
+		j.DashboardType = job.DashboardType
+		j.JobVariant = job.JobVariant
 		j.Successes += job.Successes
 		j.Failures += job.Failures
 		jobRunsByName[job.Name] = j
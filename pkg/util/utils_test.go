@@ -0,0 +1,39 @@
+package util
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputeLookbackFrom(t *testing.T) {
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	// ten runs half a day into each of the last ten days, newest first.
+	timestamps := []int{}
+	for i := 0; i < 10; i++ {
+		timestamps = append(timestamps, int(now.Add(-time.Duration(i*24+12)*time.Hour).Unix()*1000))
+	}
+
+	tests := []struct {
+		name       string
+		startDay   int
+		lookback   int
+		timestamps []int
+		start      int
+		end        int
+	}{
+		{name: "last week", startDay: 0, lookback: 7, timestamps: timestamps, start: 0, end: 7},
+		{name: "window in the past", startDay: 2, lookback: 7, timestamps: timestamps, start: 2, end: 7},
+		{name: "single day", startDay: 3, lookback: 4, timestamps: timestamps, start: 3, end: 4},
+		{name: "lookback before the oldest run", startDay: 0, lookback: 30, timestamps: timestamps, start: 0, end: 10},
+		{name: "start after the oldest run", startDay: 20, lookback: 30, timestamps: timestamps, start: math.MaxInt32, end: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := ComputeLookbackFrom(now, tt.startDay, tt.lookback, tt.timestamps)
+			if start != tt.start || end != tt.end {
+				t.Errorf("expected columns %d to %d, got %d to %d", tt.start, tt.end, start, end)
+			}
+		})
+	}
+}
@@ -6,7 +6,8 @@ echo "Doing initial sleep before fetching testgrid data"
 sleep 600 # 10 minutes
 while [ true ]; do
   echo "Fetching new testgrid data"
-  rm -rf /data/*
+  # each fetch is written to a new snapshot under /data, the server keeps reading the
+  # previous snapshot until the new one is complete.
   /tmp/src/sippy --fetch-data /data --release 4.2 --release 4.3 --release 4.4 --release 4.5 --release 4.6 -v 4
   echo "Done fetching data, refreshing server"
   curl localhost:8080/refresh